@@ -4,32 +4,56 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"realtime-chat/internal/auth"
+	"realtime-chat/internal/broker"
 	"realtime-chat/internal/handler"
 	"realtime-chat/internal/repository"
 	"realtime-chat/internal/service"
+	"time"
 )
 
+// Validade dos tokens de acesso emitidos pelo chat
+const tokenTTL = 24 * time.Hour
+
 func main() {
 	// Inicializar repositório
-	msgRepo, err := repository.NewMessageRepository("./data")
+	// Com vários nós cada um precisa do seu diretório
+	msgRepo, err := repository.NewMessageRepository(getenv("CHAT_DATA_DIR", "./data"))
 	if err != nil {
 		log.Fatal("Erro ao inicializar repositório:", err)
 	}
 
+	// Broker entre os nós: sem CHAT_BROKER_URL o chat roda em um único nó
+	msgBroker, err := broker.New(os.Getenv("CHAT_BROKER_URL"), getenv("CHAT_BROKER_CHANNEL", broker.DefaultChannel))
+	if err != nil {
+		log.Fatal("Erro ao inicializar broker:", err)
+	}
+
 	// Inicializar hub
-	hub := service.NewHub(msgRepo)
+	hub := service.NewHub(msgRepo, msgBroker)
 	go hub.Run()
 
+	// Segredo dos tokens: compartilhe CHAT_JWT_SECRET entre os nós e com o
+	// serviço que autentica os usuários para que os tokens sejam aceitos
+	secret := []byte(os.Getenv("CHAT_JWT_SECRET"))
+	if len(secret) == 0 {
+		log.Println("⚠️  CHAT_JWT_SECRET não definido: usando segredo aleatório, os tokens expiram ao reiniciar")
+		secret = auth.RandomSecret()
+	}
+	signer := auth.NewSigner(secret, tokenTTL)
+
 	// Inicializar handlers
-	wsHandler := handler.NewWebSocketHandler(hub)
+	wsHandler := handler.NewWebSocketHandler(hub, signer)
+	authHandler := handler.NewAuthHandler(hub, signer)
 	httpHandler := handler.NewHTTPHandler(hub, msgRepo)
 
 	// Configurar rotas
 	mux := http.NewServeMux()
-	
+
 	// WebSocket
 	mux.HandleFunc("/ws", wsHandler.HandleWebSocket)
-	
+
 	// API REST
 	mux.HandleFunc("/api/rooms", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodGet {
@@ -39,17 +63,19 @@ func main() {
 		}
 	})
 	mux.HandleFunc("/api/messages", httpHandler.GetMessages)
-	
+	mux.HandleFunc("/api/auth/guest", authHandler.Guest)
+
 	// Interface web
 	mux.HandleFunc("/", httpHandler.ServeHTML)
 
 	// Middleware CORS e logging
 	handler := corsMiddleware(loggingMiddleware(mux))
 
-	port := "8080"
+	port := getenv("CHAT_PORT", "8080")
 	fmt.Printf("🚀 Chat Server iniciado em http://localhost:%s\n", port)
 	fmt.Println("\n📚 Endpoints:")
-	fmt.Println("   WebSocket: ws://localhost:%s/ws?room=general&username=Joao")
+	fmt.Printf("   WebSocket: ws://localhost:%s/ws?token=<token> (subprotocolo chat.v1)\n", port)
+	fmt.Println("   POST /api/auth/guest - Obter token de acesso (convidado)")
 	fmt.Println("   GET  /api/rooms     - Listar salas")
 	fmt.Println("   POST /api/rooms     - Criar sala")
 	fmt.Println("   GET  /api/messages  - Histórico de mensagens")
@@ -57,6 +83,13 @@ func main() {
 	log.Fatal(http.ListenAndServe(":"+port, handler))
 }
 
+func getenv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
 func loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		log.Printf("[%s] %s - %s", r.Method, r.URL.Path, r.RemoteAddr)
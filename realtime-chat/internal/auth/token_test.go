@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// forge monta um token assinado com o segredo a partir de header e claims
+// brutos, como faria outro serviço
+func forge(secret []byte, header, claims string) string {
+	s := NewSigner(secret, time.Hour)
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(header)) + "." + base64.RawURLEncoding.EncodeToString([]byte(claims))
+	return unsigned + "." + s.sign(unsigned)
+}
+
+func jsonClaims(sub string, exp int64) string {
+	return fmt.Sprintf(`{"sub":%q,"name":"Ana","exp":%d}`, sub, exp)
+}
+
+func TestSignerRoundTrip(t *testing.T) {
+	s := NewSigner([]byte("segredo"), time.Hour)
+	token, issued, err := s.Issue("u1", "Ana")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := s.Verify(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *claims != *issued {
+		t.Errorf("claims = %+v, esperado %+v", claims, issued)
+	}
+}
+
+func TestSignerRejects(t *testing.T) {
+	secret := []byte("segredo")
+	s := NewSigner(secret, time.Hour)
+	valid, _, err := s.Issue("u1", "Ana")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, _ := NewSigner([]byte("outro"), time.Hour).Issue("u1", "Ana")
+
+	exp := time.Now().Add(time.Hour).Unix()
+	past := time.Now().Add(-time.Minute).Unix()
+	hs256 := `{"alg":"HS256","typ":"JWT"}`
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"assinatura de outro segredo", other, ErrInvalidToken},
+		{"assinatura alterada", valid[:len(valid)-2] + "xx", ErrInvalidToken},
+		{"formato inválido", "abc.def", ErrInvalidToken},
+		{"alg none", forge(secret, `{"alg":"none","typ":"JWT"}`, jsonClaims("u1", exp)), ErrInvalidToken},
+		{"alg HS512", forge(secret, `{"alg":"HS512","typ":"JWT"}`, jsonClaims("u1", exp)), ErrInvalidToken},
+		{"expirado", forge(secret, hs256, jsonClaims("u1", past)), ErrTokenExpired},
+		{"sem sub", forge(secret, hs256, jsonClaims("", exp)), ErrInvalidToken},
+		{"sem exp", forge(secret, hs256, `{"sub":"u1","name":"Ana"}`), ErrInvalidToken},
+		{"exp zero", forge(secret, hs256, jsonClaims("u1", 0)), ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		if _, err := s.Verify(tt.token); !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, esperado %v", tt.name, err, tt.want)
+		}
+	}
+}
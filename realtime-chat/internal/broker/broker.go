@@ -0,0 +1,173 @@
+package broker
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Canal padrão dos eventos do chat no broker
+const DefaultChannel = "realtime-chat:events"
+
+// Capacidade da fila de cada inscrito
+const subscriberBuffer = 1024
+
+var (
+	ErrClosed      = errors.New("broker encerrado")
+	ErrUnavailable = errors.New("broker indisponível")
+)
+
+// Broker distribui os eventos do chat entre as instâncias do servidor. Cada
+// nó publica os eventos e recebe, pela inscrição, os de todos os nós,
+// inclusive os seus.
+type Broker interface {
+	Publish(payload []byte) error
+	Subscribe() (<-chan []byte, error)
+	Close() error
+}
+
+// Registry guarda reservas com validade compartilhadas por todos os nós
+// ligados ao broker, como os nomes de exibição em uso. Reservas não
+// renovadas expiram sozinhas, de modo que um nó que cai não prende as suas.
+type Registry interface {
+	// Claim reserva key para owner por ttl, renovando a reserva se owner já
+	// for o dono; retorna false se a reserva for de outro dono
+	Claim(key, owner string, ttl time.Duration) (bool, error)
+	// Release remove a reserva se owner for o dono
+	Release(key, owner string) error
+	// Holder retorna o dono da reserva, vazio se estiver livre
+	Holder(key string) (string, error)
+}
+
+var (
+	_ Registry = (*MemoryBroker)(nil)
+	_ Registry = (*RedisBroker)(nil)
+)
+
+// New cria o broker a partir da URL: vazia ou memory:// para um único
+// processo, redis://[usuario:senha@]host:porta para o protocolo do Redis
+func New(rawURL, channel string) (Broker, error) {
+	if rawURL == "" {
+		return NewMemoryBroker(), nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("URL do broker inválida: %w", err)
+	}
+
+	switch u.Scheme {
+	case "memory":
+		return NewMemoryBroker(), nil
+	case "redis":
+		return NewRedisBroker(u, channel)
+	default:
+		return nil, fmt.Errorf("broker não suportado: %q (use memory ou redis)", u.Scheme)
+	}
+}
+
+// MemoryBroker entrega os eventos dentro do próprio processo. É o padrão
+// quando há um único nó.
+type MemoryBroker struct {
+	mu     sync.Mutex
+	subs   []chan []byte
+	closed bool
+	claims map[string]memoryClaim
+}
+
+type memoryClaim struct {
+	owner   string
+	expires time.Time
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{claims: make(map[string]memoryClaim)}
+}
+
+// Publish entrega o evento a todos os inscritos. Se a fila de algum estiver
+// cheia o evento é descartado para ele e Publish retorna ErrUnavailable,
+// como o RedisBroker com a fila de saída cheia.
+func (b *MemoryBroker) Publish(payload []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return ErrClosed
+	}
+
+	// Não bloqueia: o hub publica e consome na mesma goroutine
+	var err error
+	for _, sub := range b.subs {
+		select {
+		case sub <- payload:
+		default:
+			log.Println("⚠️  Inscrito lento no broker, evento descartado")
+			err = ErrUnavailable
+		}
+	}
+	return err
+}
+
+func (b *MemoryBroker) Subscribe() (<-chan []byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, ErrClosed
+	}
+
+	sub := make(chan []byte, subscriberBuffer)
+	b.subs = append(b.subs, sub)
+	return sub, nil
+}
+
+func (b *MemoryBroker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	for _, sub := range b.subs {
+		close(sub)
+	}
+	b.subs = nil
+	return nil
+}
+
+func (b *MemoryBroker) Claim(key, owner string, ttl time.Duration) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	if claim, ok := b.claims[key]; ok && claim.owner != owner && now.Before(claim.expires) {
+		return false, nil
+	}
+	b.claims[key] = memoryClaim{owner: owner, expires: now.Add(ttl)}
+	return true, nil
+}
+
+func (b *MemoryBroker) Release(key, owner string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if claim, ok := b.claims[key]; ok && claim.owner == owner {
+		delete(b.claims, key)
+	}
+	return nil
+}
+
+func (b *MemoryBroker) Holder(key string) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	claim, ok := b.claims[key]
+	if !ok || !time.Now().Before(claim.expires) {
+		return "", nil
+	}
+	return claim.owner, nil
+}
@@ -0,0 +1,34 @@
+package broker
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMemoryBrokerReportsFullSubscriber(t *testing.T) {
+	b := NewMemoryBroker()
+	defer b.Close()
+
+	fast, err := b.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Subscribe(); err != nil { // nunca lido
+		t.Fatal(err)
+	}
+
+	for i := range subscriberBuffer {
+		if err := b.Publish([]byte("x")); err != nil {
+			t.Fatalf("Publish %d: %v", i, err)
+		}
+		<-fast
+	}
+
+	// A fila do inscrito lento encheu: o evento se perde para ele
+	if err := b.Publish([]byte("y")); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Publish com inscrito cheio: err = %v, esperado ErrUnavailable", err)
+	}
+	if got := string(<-fast); got != "y" {
+		t.Errorf("inscrito com espaço recebeu %q, esperado y", got)
+	}
+}
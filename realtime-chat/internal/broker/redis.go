@@ -0,0 +1,483 @@
+package broker
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	redisDefaultPort = "6379"
+	redisTimeout     = 5 * time.Second  // conexão e cada comando
+	redisMaxBackoff  = 30 * time.Second // espera máxima entre reconexões
+	publishQueueSize = 1024
+	maxBulkSize      = 1 << 20 // maior evento aceito na leitura
+	maxArrayLen      = 1024
+)
+
+var errProtocol = errors.New("resposta inválida do servidor Redis")
+
+// Scripts das reservas: o teste e a escrita são atômicos no servidor
+const (
+	claimScript = `local owner = redis.call('GET', KEYS[1])
+if owner == false or owner == ARGV[1] then
+  redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+  return 1
+end
+return 0`
+
+	releaseScript = `if redis.call('GET', KEYS[1]) == ARGV[1] then
+  return redis.call('DEL', KEYS[1])
+end
+return 0`
+)
+
+// redisError é uma resposta de erro do servidor (-ERR ...)
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// RedisBroker usa o PUBLISH/SUBSCRIBE de um servidor compatível com o
+// protocolo do Redis (RESP). Uma conexão publica os eventos a partir de uma
+// fila e cada inscrição tem a sua própria; as duas reconectam sozinhas.
+// Eventos publicados enquanto um nó está desconectado não chegam a ele.
+// As reservas do Registry são chaves com validade (scripts com EVAL), com
+// o nome do canal como prefixo, numa terceira conexão aberta sob demanda.
+type RedisBroker struct {
+	addr     string
+	username string
+	password string
+	channel  string
+
+	outbox    chan []byte
+	connected atomic.Bool // conexão de publicação ativa
+	done      chan struct{}
+	wg        sync.WaitGroup
+
+	mu     sync.Mutex
+	closed bool
+	conns  map[net.Conn]struct{} // fechadas em Close para desbloquear as leituras
+
+	cmdMu   sync.Mutex // serializa os comandos das reservas
+	cmdConn net.Conn
+	cmdR    *bufio.Reader
+}
+
+// NewRedisBroker conecta ao servidor em segundo plano; enquanto a conexão
+// não sobe, Publish retorna ErrUnavailable
+func NewRedisBroker(u *url.URL, channel string) (*RedisBroker, error) {
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("URL do broker sem host: %s", u.Redacted())
+	}
+	if channel == "" {
+		channel = DefaultChannel
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = redisDefaultPort
+	}
+
+	b := &RedisBroker{
+		addr:    net.JoinHostPort(u.Hostname(), port),
+		channel: channel,
+		outbox:  make(chan []byte, publishQueueSize),
+		done:    make(chan struct{}),
+		conns:   make(map[net.Conn]struct{}),
+	}
+	if u.User != nil {
+		b.username = u.User.Username()
+		b.password, _ = u.User.Password()
+	}
+
+	b.wg.Add(1)
+	go b.runPublisher()
+	return b, nil
+}
+
+// Publish enfileira o evento. Com a conexão caída ou a fila cheia retorna
+// ErrUnavailable para o chamador decidir o que fazer com ele.
+func (b *RedisBroker) Publish(payload []byte) error {
+	select {
+	case <-b.done:
+		return ErrClosed
+	default:
+	}
+
+	if !b.connected.Load() {
+		return ErrUnavailable
+	}
+
+	select {
+	case b.outbox <- payload:
+		return nil
+	default:
+		return ErrUnavailable
+	}
+}
+
+func (b *RedisBroker) Subscribe() (<-chan []byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, ErrClosed
+	}
+
+	out := make(chan []byte, subscriberBuffer)
+	b.wg.Add(1)
+	go b.runSubscriber(out)
+	return out, nil
+}
+
+// Close encerra as conexões e espera as goroutines terminarem; os canais
+// das inscrições são fechados
+func (b *RedisBroker) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	close(b.done)
+	for conn := range b.conns {
+		conn.Close()
+	}
+	b.mu.Unlock()
+
+	b.wg.Wait()
+	return nil
+}
+
+func (b *RedisBroker) Claim(key, owner string, ttl time.Duration) (bool, error) {
+	reply, err := b.command("EVAL", claimScript, "1", b.registryKey(key), owner, strconv.FormatInt(ttl.Milliseconds(), 10))
+	if err != nil {
+		return false, err
+	}
+	n, ok := reply.(int64)
+	if !ok {
+		return false, errProtocol
+	}
+	return n == 1, nil
+}
+
+func (b *RedisBroker) Release(key, owner string) error {
+	_, err := b.command("EVAL", releaseScript, "1", b.registryKey(key), owner)
+	return err
+}
+
+func (b *RedisBroker) Holder(key string) (string, error) {
+	reply, err := b.command("GET", b.registryKey(key))
+	if err != nil || reply == nil {
+		return "", err
+	}
+	owner, ok := reply.([]byte)
+	if !ok {
+		return "", errProtocol
+	}
+	return string(owner), nil
+}
+
+func (b *RedisBroker) registryKey(key string) string {
+	return b.channel + ":" + key
+}
+
+// command executa um comando na conexão das reservas, abrindo-a se preciso;
+// erros de rede a descartam para a próxima chamada reconectar
+func (b *RedisBroker) command(args ...string) (any, error) {
+	b.cmdMu.Lock()
+	defer b.cmdMu.Unlock()
+
+	if b.isClosed() {
+		return nil, ErrClosed
+	}
+	if b.cmdConn == nil {
+		conn, r, err := b.dial()
+		if err != nil {
+			return nil, err
+		}
+		b.cmdConn, b.cmdR = conn, r
+	}
+
+	reply, err := b.roundTrip(b.cmdConn, b.cmdR, args...)
+	var replyErr redisError
+	if err != nil && !errors.As(err, &replyErr) {
+		b.release(b.cmdConn)
+		b.cmdConn, b.cmdR = nil, nil
+	}
+	return reply, err
+}
+
+func (b *RedisBroker) runPublisher() {
+	defer b.wg.Done()
+
+	var pending []byte // evento que falhou e será reenviado após reconectar
+	for failures := 0; ; failures++ {
+		conn, r, err := b.dial()
+		if err == nil {
+			failures = -1
+			b.connected.Store(true)
+			log.Printf("📡 Broker Redis conectado: %s (canal %s)", b.addr, b.channel)
+			err = b.publishLoop(conn, r, &pending)
+			b.connected.Store(false)
+			b.release(conn)
+		}
+
+		if b.isClosed() {
+			return
+		}
+		log.Printf("⚠️  Publicação no broker Redis interrompida: %v", err)
+		if !b.wait(backoff(failures)) {
+			return
+		}
+	}
+}
+
+func (b *RedisBroker) publishLoop(conn net.Conn, r *bufio.Reader, pending *[]byte) error {
+	for {
+		if *pending == nil {
+			select {
+			case *pending = <-b.outbox:
+			case <-b.done:
+				return ErrClosed
+			}
+		}
+
+		_, err := b.roundTrip(conn, r, "PUBLISH", b.channel, string(*pending))
+		var replyErr redisError
+		if err != nil && !errors.As(err, &replyErr) {
+			return err
+		}
+		if err != nil {
+			// Recusado pelo servidor: reenviar não adiantaria
+			log.Printf("⚠️  Evento recusado pelo broker: %v", err)
+		}
+		*pending = nil
+	}
+}
+
+func (b *RedisBroker) runSubscriber(out chan<- []byte) {
+	defer b.wg.Done()
+	defer close(out)
+
+	for failures := 0; ; failures++ {
+		conn, r, err := b.dial()
+		if err == nil {
+			err = b.subscribeLoop(conn, r, out, &failures)
+			b.release(conn)
+		}
+
+		if b.isClosed() {
+			return
+		}
+		log.Printf("⚠️  Inscrição no broker Redis interrompida: %v", err)
+		if !b.wait(backoff(failures)) {
+			return
+		}
+	}
+}
+
+func (b *RedisBroker) subscribeLoop(conn net.Conn, r *bufio.Reader, out chan<- []byte, failures *int) error {
+	reply, err := b.roundTrip(conn, r, "SUBSCRIBE", b.channel)
+	if err != nil {
+		return err
+	}
+	if kind, _ := pushKind(reply); kind != "subscribe" {
+		return errProtocol
+	}
+
+	// Inscrito: a conexão fica ociosa até chegar um evento
+	conn.SetDeadline(time.Time{})
+	*failures = -1
+
+	for {
+		reply, err := readReply(r)
+		if err != nil {
+			return err
+		}
+
+		kind, items := pushKind(reply)
+		if kind != "message" || len(items) != 3 {
+			continue
+		}
+		payload, ok := items[2].([]byte)
+		if !ok {
+			continue
+		}
+
+		select {
+		case out <- payload:
+		case <-b.done:
+			return ErrClosed
+		}
+	}
+}
+
+// dial abre uma conexão e autentica, se a URL trouxer senha
+func (b *RedisBroker) dial() (net.Conn, *bufio.Reader, error) {
+	conn, err := net.DialTimeout("tcp", b.addr, redisTimeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !b.track(conn) {
+		conn.Close()
+		return nil, nil, ErrClosed
+	}
+
+	r := bufio.NewReader(conn)
+	if b.password != "" {
+		args := []string{"AUTH", b.password}
+		if b.username != "" {
+			args = []string{"AUTH", b.username, b.password}
+		}
+		if _, err := b.roundTrip(conn, r, args...); err != nil {
+			b.release(conn)
+			return nil, nil, err
+		}
+	}
+	return conn, r, nil
+}
+
+// roundTrip envia um comando e lê a resposta, com prazo
+func (b *RedisBroker) roundTrip(conn net.Conn, r *bufio.Reader, args ...string) (any, error) {
+	conn.SetDeadline(time.Now().Add(redisTimeout))
+	if err := writeCommand(conn, args...); err != nil {
+		return nil, err
+	}
+	return readReply(r)
+}
+
+func (b *RedisBroker) track(conn net.Conn) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return false
+	}
+	b.conns[conn] = struct{}{}
+	return true
+}
+
+func (b *RedisBroker) release(conn net.Conn) {
+	b.mu.Lock()
+	delete(b.conns, conn)
+	b.mu.Unlock()
+	conn.Close()
+}
+
+func (b *RedisBroker) isClosed() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.closed
+}
+
+// wait espera d ou o encerramento do broker; retorna false se encerrado
+func (b *RedisBroker) wait(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-b.done:
+		return false
+	}
+}
+
+// backoff dobra a espera a cada falha seguida: 1s, 2s, 4s... até 30s
+func backoff(failures int) time.Duration {
+	if failures < 0 {
+		failures = 0
+	}
+	if failures >= 5 {
+		return redisMaxBackoff
+	}
+	return min(time.Second<<failures, redisMaxBackoff)
+}
+
+// writeCommand escreve o comando como array de bulk strings
+func writeCommand(w io.Writer, args ...string) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&buf, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// readReply lê uma resposta RESP: string simples, inteiro, bulk string
+// ([]byte, nil se nula) ou array. Respostas de erro retornam redisError.
+func readReply(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, errProtocol
+	}
+	kind, body := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, redisError(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil || n > maxBulkSize {
+			return nil, errProtocol
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		if buf[n] != '\r' || buf[n+1] != '\n' {
+			return nil, errProtocol
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil || n > maxArrayLen {
+			return nil, errProtocol
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = readReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	default:
+		return nil, errProtocol
+	}
+}
+
+// pushKind retorna o tipo de uma mensagem de pub/sub ("subscribe",
+// "message"...) e seus itens
+func pushKind(reply any) (string, []any) {
+	items, ok := reply.([]any)
+	if !ok || len(items) == 0 {
+		return "", nil
+	}
+	kind, ok := items[0].([]byte)
+	if !ok {
+		return "", nil
+	}
+	return string(kind), items
+}
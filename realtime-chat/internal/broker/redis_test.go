@@ -0,0 +1,506 @@
+package broker
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis é um servidor RESP mínimo com AUTH, SUBSCRIBE, PUBLISH, GET e
+// os scripts das reservas (reconhecidos pelo texto, sem interpretar Lua)
+type fakeRedis struct {
+	ln       net.Listener
+	password string
+
+	mu              sync.Mutex // protege os campos abaixo e as escritas nas conexões
+	conns           map[net.Conn]bool
+	subs            map[string]map[net.Conn]bool // canal -> inscritos
+	authFailures    int
+	published       int
+	dropNextPublish bool // fecha a conexão ao receber o próximo PUBLISH, sem entregá-lo
+	keys            map[string]fakeKey
+}
+
+type fakeKey struct {
+	value   string
+	expires time.Time
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &fakeRedis{
+		ln:       ln,
+		password: password,
+		conns:    make(map[net.Conn]bool),
+		subs:     make(map[string]map[net.Conn]bool),
+		keys:     make(map[string]fakeKey),
+	}
+	go s.serve()
+	t.Cleanup(func() {
+		ln.Close()
+		s.dropAll()
+	})
+	return s
+}
+
+// url retorna a URL do broker com a senha informada
+func (s *fakeRedis) url(password string) *url.URL {
+	u := &url.URL{Scheme: "redis", Host: s.ln.Addr().String()}
+	if password != "" {
+		u.User = url.UserPassword("", password)
+	}
+	return u
+}
+
+func (s *fakeRedis) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = true
+		s.mu.Unlock()
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeRedis) handle(conn net.Conn) {
+	defer s.forget(conn)
+
+	r := bufio.NewReader(conn)
+	authed := s.password == ""
+	for {
+		reply, err := readReply(r)
+		if err != nil {
+			return
+		}
+		items, ok := reply.([]any)
+		if !ok || len(items) == 0 {
+			return
+		}
+		args := make([]string, len(items))
+		for i, item := range items {
+			arg, _ := item.([]byte)
+			args[i] = string(arg)
+		}
+
+		switch cmd := strings.ToUpper(args[0]); {
+		case cmd == "AUTH":
+			if args[len(args)-1] != s.password {
+				s.mu.Lock()
+				s.authFailures++
+				s.mu.Unlock()
+				s.write(conn, "-WRONGPASS invalid username-password pair\r\n")
+				continue
+			}
+			authed = true
+			s.write(conn, "+OK\r\n")
+
+		case !authed:
+			s.write(conn, "-NOAUTH Authentication required.\r\n")
+
+		case cmd == "SUBSCRIBE" && len(args) == 2:
+			s.mu.Lock()
+			if s.subs[args[1]] == nil {
+				s.subs[args[1]] = make(map[net.Conn]bool)
+			}
+			s.subs[args[1]][conn] = true
+			s.mu.Unlock()
+			s.write(conn, push("subscribe", args[1], ":1\r\n"))
+
+		case cmd == "PUBLISH" && len(args) == 3:
+			s.mu.Lock()
+			if s.dropNextPublish {
+				s.dropNextPublish = false
+				s.mu.Unlock()
+				conn.Close()
+				return
+			}
+			s.published++
+			n := 0
+			for sub := range s.subs[args[1]] {
+				writeLocked(sub, push("message", args[1], bulk(args[2])))
+				n++
+			}
+			s.mu.Unlock()
+			s.write(conn, fmt.Sprintf(":%d\r\n", n))
+
+		case cmd == "GET" && len(args) == 2:
+			value, ok := s.get(args[1])
+			if !ok {
+				s.write(conn, "$-1\r\n")
+				continue
+			}
+			s.write(conn, bulk(value))
+
+		case cmd == "EVAL" && len(args) >= 4:
+			s.write(conn, s.eval(args[1], args[3], args[4:]))
+
+		default:
+			s.write(conn, "-ERR unknown command '"+args[0]+"'\r\n")
+		}
+	}
+}
+
+func (s *fakeRedis) get(key string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, ok := s.keys[key]
+	if !ok || !time.Now().Before(k.expires) {
+		return "", false
+	}
+	return k.value, true
+}
+
+// eval emula os scripts das reservas
+func (s *fakeRedis) eval(script, key string, argv []string) string {
+	value, exists := s.get(key)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case script == claimScript && len(argv) == 2:
+		if exists && value != argv[0] {
+			return ":0\r\n"
+		}
+		ms, _ := strconv.Atoi(argv[1])
+		s.keys[key] = fakeKey{value: argv[0], expires: time.Now().Add(time.Duration(ms) * time.Millisecond)}
+		return ":1\r\n"
+	case script == releaseScript && len(argv) == 1:
+		if !exists || value != argv[0] {
+			return ":0\r\n"
+		}
+		delete(s.keys, key)
+		return ":1\r\n"
+	default:
+		return "-ERR script desconhecido\r\n"
+	}
+}
+
+func (s *fakeRedis) write(conn net.Conn, reply string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeLocked(conn, reply)
+}
+
+func writeLocked(conn net.Conn, reply string) {
+	conn.SetWriteDeadline(time.Now().Add(time.Second))
+	conn.Write([]byte(reply))
+}
+
+func (s *fakeRedis) forget(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conn.Close()
+	delete(s.conns, conn)
+	for _, subs := range s.subs {
+		delete(subs, conn)
+	}
+}
+
+// dropAll derruba todas as conexões, como numa queda do servidor
+func (s *fakeRedis) dropAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+}
+
+func (s *fakeRedis) subscribers(channel string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.subs[channel])
+}
+
+func (s *fakeRedis) stats() (authFailures, published int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.authFailures, s.published
+}
+
+// push monta uma mensagem de pub/sub; o último item já vem codificado
+func push(kind, channel, last string) string {
+	return "*3\r\n" + bulk(kind) + bulk(channel) + last
+}
+
+func bulk(s string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s)
+}
+
+func newTestRedisBroker(t *testing.T, u *url.URL) *RedisBroker {
+	t.Helper()
+	b, err := NewRedisBroker(u, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { b.Close() })
+	return b
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("tempo esgotado esperando %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func receive(t *testing.T, events <-chan []byte) string {
+	t.Helper()
+	select {
+	case payload, ok := <-events:
+		if !ok {
+			t.Fatal("inscrição encerrada")
+		}
+		return string(payload)
+	case <-time.After(5 * time.Second):
+		t.Fatal("evento não recebido")
+		return ""
+	}
+}
+
+func expectNothing(t *testing.T, events <-chan []byte) {
+	t.Helper()
+	select {
+	case payload := <-events:
+		t.Fatalf("evento inesperado: %q", payload)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestRedisBrokerFanOut(t *testing.T) {
+	srv := newFakeRedis(t, "s3cr3t")
+	a := newTestRedisBroker(t, srv.url("s3cr3t"))
+	b := newTestRedisBroker(t, srv.url("s3cr3t"))
+
+	eventsA, err := a.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventsB, err := b.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "as inscrições", func() bool { return srv.subscribers("test") == 2 })
+	waitFor(t, "a conexão de publicação", a.connected.Load)
+
+	// O conteúdo é binário: quebras de linha não podem confundir o protocolo
+	payload := "{\"text\":\"linha 1\r\nlinha 2\"}"
+	if err := a.Publish([]byte(payload)); err != nil {
+		t.Fatal(err)
+	}
+	for name, events := range map[string]<-chan []byte{"a": eventsA, "b": eventsB} {
+		if got := receive(t, events); got != payload {
+			t.Errorf("nó %s recebeu %q, esperado %q", name, got, payload)
+		}
+	}
+}
+
+func TestRedisBrokerRejectsWrongPassword(t *testing.T) {
+	srv := newFakeRedis(t, "s3cr3t")
+	b := newTestRedisBroker(t, srv.url("errada"))
+
+	if _, err := b.Subscribe(); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "as tentativas de AUTH", func() bool {
+		failures, _ := srv.stats()
+		return failures >= 2 // publicação e inscrição
+	})
+
+	if b.connected.Load() {
+		t.Error("broker conectado com senha errada")
+	}
+	if err := b.Publish([]byte("x")); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Publish: err = %v, esperado ErrUnavailable", err)
+	}
+	if n := srv.subscribers("test"); n != 0 {
+		t.Errorf("%d inscrições sem autenticação", n)
+	}
+}
+
+func TestRedisBrokerReconnects(t *testing.T) {
+	srv := newFakeRedis(t, "")
+	b := newTestRedisBroker(t, srv.url(""))
+
+	events, err := b.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "a inscrição", func() bool { return srv.subscribers("test") == 1 })
+	waitFor(t, "a conexão de publicação", b.connected.Load)
+
+	srv.dropAll()
+	waitFor(t, "a queda da inscrição", func() bool { return srv.subscribers("test") == 0 })
+	waitFor(t, "a nova inscrição", func() bool { return srv.subscribers("test") == 1 })
+
+	// A conexão de publicação ociosa só percebe a queda ao publicar; o
+	// evento é reenviado na nova conexão
+	for _, payload := range []string{"primeiro", "segundo"} {
+		if err := b.Publish([]byte(payload)); err != nil {
+			t.Fatal(err)
+		}
+		if got := receive(t, events); got != payload {
+			t.Errorf("recebido %q, esperado %q", got, payload)
+		}
+	}
+	if !b.connected.Load() {
+		t.Error("conexão de publicação não restabelecida")
+	}
+}
+
+func TestRedisBrokerReplaysPendingPublish(t *testing.T) {
+	srv := newFakeRedis(t, "")
+	b := newTestRedisBroker(t, srv.url(""))
+
+	events, err := b.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "a inscrição", func() bool { return srv.subscribers("test") == 1 })
+	waitFor(t, "a conexão de publicação", b.connected.Load)
+
+	// A conexão cai antes da resposta ao PUBLISH: o evento é reenviado
+	// depois de reconectar, uma única vez
+	srv.mu.Lock()
+	srv.dropNextPublish = true
+	srv.mu.Unlock()
+	if err := b.Publish([]byte("pendente")); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := receive(t, events); got != "pendente" {
+		t.Errorf("recebido %q, esperado \"pendente\"", got)
+	}
+	expectNothing(t, events)
+	if _, published := srv.stats(); published != 1 {
+		t.Errorf("%d publicações entregues, esperado 1", published)
+	}
+}
+
+func TestRedisBrokerCloseEndsSubscriptions(t *testing.T) {
+	srv := newFakeRedis(t, "")
+	b := newTestRedisBroker(t, srv.url(""))
+
+	events, err := b.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "a inscrição", func() bool { return srv.subscribers("test") == 1 })
+
+	b.Close()
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Error("evento inesperado após Close")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("canal da inscrição não foi fechado")
+	}
+	if err := b.Publish([]byte("x")); !errors.Is(err, ErrClosed) {
+		t.Errorf("Publish após Close: err = %v, esperado ErrClosed", err)
+	}
+	if _, err := b.Subscribe(); !errors.Is(err, ErrClosed) {
+		t.Errorf("Subscribe após Close: err = %v, esperado ErrClosed", err)
+	}
+}
+
+func TestRedisBrokerRegistry(t *testing.T) {
+	srv := newFakeRedis(t, "s3cr3t")
+	a := newTestRedisBroker(t, srv.url("s3cr3t"))
+	b := newTestRedisBroker(t, srv.url("s3cr3t"))
+
+	claim := func(r Registry, owner string, ttl time.Duration) bool {
+		t.Helper()
+		ok, err := r.Claim("name:ana", owner, ttl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ok
+	}
+	holder := func(r Registry) string {
+		t.Helper()
+		owner, err := r.Holder("name:ana")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return owner
+	}
+
+	if !claim(a, "u1", time.Minute) {
+		t.Fatal("nome livre recusado")
+	}
+	if claim(b, "u2", time.Minute) {
+		t.Error("nome de outro usuário reservado em outro nó")
+	}
+	if !claim(b, "u1", time.Minute) {
+		t.Error("renovação pelo mesmo usuário recusada")
+	}
+	if got := holder(b); got != "u1" {
+		t.Errorf("Holder = %q, esperado u1", got)
+	}
+	if _, ok := srv.get("test:name:ana"); !ok {
+		t.Error("reserva sem o prefixo do canal")
+	}
+
+	// Só o dono remove a reserva
+	if err := b.Release("name:ana", "u2"); err != nil {
+		t.Fatal(err)
+	}
+	if got := holder(a); got != "u1" {
+		t.Errorf("Holder = %q após Release de outro usuário, esperado u1", got)
+	}
+	if err := a.Release("name:ana", "u1"); err != nil {
+		t.Fatal(err)
+	}
+	if got := holder(a); got != "" {
+		t.Errorf("Holder = %q após Release, esperado vazio", got)
+	}
+
+	// Reservas não renovadas expiram
+	if !claim(a, "u1", 50*time.Millisecond) {
+		t.Fatal("nome liberado recusado")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if !claim(b, "u2", time.Minute) {
+		t.Error("reserva expirada ainda bloqueia o nome")
+	}
+}
+
+func TestRedisBrokerRegistryReconnects(t *testing.T) {
+	srv := newFakeRedis(t, "")
+	b := newTestRedisBroker(t, srv.url(""))
+
+	if ok, err := b.Claim("name:ana", "u1", time.Minute); err != nil || !ok {
+		t.Fatalf("Claim: %v, %v", ok, err)
+	}
+
+	// A primeira chamada depois da queda falha; a seguinte reconecta
+	srv.dropAll()
+	waitFor(t, "a reconexão", func() bool {
+		owner, err := b.Holder("name:ana")
+		return err == nil && owner == "u1"
+	})
+
+	b.Close()
+	if _, err := b.Holder("name:ana"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Holder após Close: err = %v, esperado ErrClosed", err)
+	}
+}
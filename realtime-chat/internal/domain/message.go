@@ -2,13 +2,19 @@ package domain
 
 import "time"
 
+// Message é uma mensagem da sala ou um evento sobre ela. Só "text" vai para
+// o histórico; "edit" e "delete" alteram uma mensagem já gravada (ID) e
+// "typing" e "read" são avisos passageiros.
 type Message struct {
-	ID        string    `json:"id"`
-	RoomID    string    `json:"room_id"`
-	Username  string    `json:"username"`
-	Content   string    `json:"content"`
-	Type      string    `json:"type"` // "text", "join", "leave", "system"
-	CreatedAt time.Time `json:"created_at"`
+	ID        string     `json:"id"`
+	RoomID    string     `json:"room_id"`
+	UserID    string     `json:"user_id,omitempty"` // vazio nas mensagens do sistema
+	Username  string     `json:"username"`
+	Content   string     `json:"content"`
+	Type      string     `json:"type"` // "text", "join", "leave", "system", "edit", "delete", "typing", "read"
+	CreatedAt time.Time  `json:"created_at"`
+	EditedAt  *time.Time `json:"edited_at,omitempty"`
+	Deleted   bool       `json:"deleted,omitempty"` // apagada: o conteúdo vem vazio
 }
 
 type MessageRequest struct {
@@ -18,6 +24,8 @@ type MessageRequest struct {
 }
 
 type MessageResponse struct {
-	Messages []Message `json:"messages"`
-	RoomID   string    `json:"room_id"`
+	Messages   []Message `json:"messages"`
+	RoomID     string    `json:"room_id"`
+	HasMore    bool      `json:"has_more"`              // há mensagens mais antigas
+	NextBefore string    `json:"next_before,omitempty"` // cursor da página anterior
 }
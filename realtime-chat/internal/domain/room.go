@@ -22,11 +22,16 @@ type Room struct {
 	broadcast   chan Message     // não exportado
 }
 
+// Client é uma conexão WebSocket; a mesma conexão pode estar em várias salas
 type Client struct {
 	ID       string
+	UserID   string // usuário verificado pelo token de acesso
 	Username string
-	RoomID   string
 	Send     chan Message
+
+	mu     sync.Mutex
+	rooms  map[string]bool
+	closed bool
 }
 
 type RoomInfo struct {
@@ -57,7 +62,6 @@ func (r *Room) RemoveClient(client *Client) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	delete(r.clients, client)
-	close(client.Send)
 }
 
 func (r *Room) GetClients() []*Client {
@@ -83,3 +87,78 @@ func (r *Room) Broadcast() chan<- Message {
 func (r *Room) GetBroadcastChan() <-chan Message {
 	return r.broadcast
 }
+
+func NewClient(id, userID, username string) *Client {
+	return &Client{
+		ID:       id,
+		UserID:   userID,
+		Username: username,
+		Send:     make(chan Message, 256),
+		rooms:    make(map[string]bool),
+	}
+}
+
+// JoinRoom marca a sala na conexão; retorna false se já estava nela
+func (c *Client) JoinRoom(roomID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.rooms[roomID] {
+		return false
+	}
+	c.rooms[roomID] = true
+	return true
+}
+
+// LeaveRoom desmarca a sala; retorna false se a conexão não estava nela
+func (c *Client) LeaveRoom(roomID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.rooms[roomID] {
+		return false
+	}
+	delete(c.rooms, roomID)
+	return true
+}
+
+func (c *Client) InRoom(roomID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rooms[roomID]
+}
+
+func (c *Client) Rooms() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	rooms := make([]string, 0, len(c.rooms))
+	for id := range c.rooms {
+		rooms = append(rooms, id)
+	}
+	return rooms
+}
+
+// Deliver envia sem bloquear; retorna false se a fila da conexão está
+// cheia ou já foi fechada
+func (c *Client) Deliver(msg Message) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return false
+	}
+	select {
+	case c.Send <- msg:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close fecha a fila de envio, encerrando a conexão; pode ser chamado mais
+// de uma vez
+func (c *Client) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.closed {
+		c.closed = true
+		close(c.Send)
+	}
+}
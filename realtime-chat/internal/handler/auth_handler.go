@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"realtime-chat/internal/auth"
+	"realtime-chat/internal/service"
+	"time"
+)
+
+type AuthHandler struct {
+	hub    *service.Hub
+	signer *auth.Signer
+}
+
+func NewAuthHandler(hub *service.Hub, signer *auth.Signer) *AuthHandler {
+	return &AuthHandler{hub: hub, signer: signer}
+}
+
+type tokenResponse struct {
+	Token     string    `json:"token"`
+	UserID    string    `json:"user_id"`
+	Name      string    `json:"name"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Guest cria uma identidade de convidado com o nome pedido e devolve o
+// token usado na conexão WebSocket: POST /api/auth/guest {"name": "..."}
+func (h *AuthHandler) Guest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "JSON inválido", http.StatusBadRequest)
+		return
+	}
+
+	name, err := service.ValidateDisplayName(req.Name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// A reserva definitiva acontece na conexão; aqui só evita emitir um
+	// token que seria recusado
+	userID := generateID()
+	available, err := h.hub.NameAvailable(userID, name)
+	if err != nil {
+		log.Printf("Erro ao consultar o registro de nomes: %v", err)
+		http.Error(w, service.ErrNamesUnavailable.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if !available {
+		http.Error(w, service.ErrNameInUse.Error(), http.StatusConflict)
+		return
+	}
+
+	token, claims, err := h.signer.Issue(userID, name)
+	if err != nil {
+		http.Error(w, "Erro ao emitir token", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(tokenResponse{
+		Token:     token,
+		UserID:    claims.Subject,
+		Name:      claims.Name,
+		ExpiresAt: time.Unix(claims.ExpiresAt, 0),
+	})
+}
@@ -2,10 +2,13 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"realtime-chat/internal/domain"
 	"realtime-chat/internal/repository"
 	"realtime-chat/internal/service"
+	"strconv"
 )
 
 type HTTPHandler struct {
@@ -43,18 +46,38 @@ func (h *HTTPHandler) GetMessages(w http.ResponseWriter, r *http.Request) {
 		roomID = "general"
 	}
 
-	limit := 50 // últimas 50 mensagens
-	messages, err := h.msgRepo.GetRecent(roomID, limit)
+	limit := repository.DefaultPageSize
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > repository.MaxPageSize {
+			http.Error(w, fmt.Sprintf("limit deve ser um número de 1 a %d", repository.MaxPageSize), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	// before é o ID da mensagem mais antiga já carregada pelo cliente
+	messages, hasMore, err := h.msgRepo.History(roomID, r.URL.Query().Get("before"), limit)
 	if err != nil {
+		if errors.Is(err, repository.ErrCursorNotFound) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(domain.MessageResponse{
+	resp := domain.MessageResponse{
 		Messages: messages,
 		RoomID:   roomID,
-	})
+		HasMore:  hasMore,
+	}
+	if hasMore && len(messages) > 0 {
+		resp.NextBefore = messages[0].ID
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
 }
 
 func (h *HTTPHandler) CreateRoom(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,270 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"realtime-chat/internal/domain"
+	"realtime-chat/internal/service"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Protocolo negociado em Sec-WebSocket-Protocol. Conexões sem subprotocolo
+// usam o formato antigo: uma sala pela query, {"content": ...} na entrada e
+// a mensagem pura na saída.
+const (
+	SubprotocolV1   = "chat.v1"
+	protocolVersion = 1
+
+	writeWait    = 10 * time.Second
+	replyBuffer  = 16
+	maxFrameSize = 512 * 1024
+)
+
+// Comandos do cliente
+const (
+	cmdJoin   = "join"   // {room}
+	cmdLeave  = "leave"  // {room}
+	cmdSend   = "send"   // {room, content}
+	cmdEdit   = "edit"   // {room, message_id, content}
+	cmdDelete = "delete" // {room, message_id}
+	cmdTyping = "typing" // {room}
+	cmdAck    = "ack"    // {room, message_id}: leu até essa mensagem
+	cmdPing   = "ping"
+)
+
+// Frames do servidor
+const (
+	frameWelcome = "welcome"
+	frameAck     = "ack"
+	frameError   = "error"
+	framePong    = "pong"
+	frameMessage = "message" // mensagens e avisos de entrada e saída
+	frameEdited  = "edited"
+	frameDeleted = "deleted"
+	frameTyping  = "typing"
+	frameRead    = "read"
+)
+
+// envelope é o frame do chat.v1 nos dois sentidos. Comandos com id recebem
+// um ack ou um error com o mesmo id; sem id só os erros são enviados.
+type envelope struct {
+	V       int             `json:"v,omitempty"`
+	Type    string          `json:"type"`
+	ID      string          `json:"id,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// commandPayload reúne os campos usados pelos comandos
+type commandPayload struct {
+	Room      string `json:"room"`
+	Content   string `json:"content"`
+	MessageID string `json:"message_id"`
+}
+
+type errorPayload struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type welcomePayload struct {
+	Protocol string   `json:"protocol"`
+	UserID   string   `json:"user_id"`
+	Name     string   `json:"name"`
+	Rooms    []string `json:"rooms"`
+}
+
+type roomPayload struct {
+	Room string `json:"room"`
+}
+
+// session é uma conexão aberta: os eventos das salas chegam por client.Send
+// e as respostas aos comandos por replies
+type session struct {
+	hub     *service.Hub
+	client  *domain.Client
+	conn    *websocket.Conn
+	v1      bool
+	replies chan envelope
+	done    chan struct{} // fechado quando o writePump termina
+
+	nameLost <-chan struct{} // fechado quando o nome passa a outro usuário
+}
+
+func newSession(hub *service.Hub, client *domain.Client, conn *websocket.Conn) *session {
+	return &session{
+		hub:     hub,
+		client:  client,
+		conn:    conn,
+		v1:      conn.Subprotocol() == SubprotocolV1,
+		replies: make(chan envelope, replyBuffer),
+		done:    make(chan struct{}),
+	}
+}
+
+// handle executa um comando do chat.v1
+func (s *session) handle(data []byte) {
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil || env.Type == "" {
+		s.fail("", "bad_request", "frame inválido: esperado {\"type\", \"id\", \"payload\"}")
+		return
+	}
+	if env.V != 0 && env.V != protocolVersion {
+		s.fail(env.ID, "unsupported_version", "versão do protocolo não suportada")
+		return
+	}
+
+	if env.Type == cmdPing {
+		s.reply(envelope{V: protocolVersion, Type: framePong, ID: env.ID, Payload: env.Payload})
+		return
+	}
+
+	var p commandPayload
+	if len(env.Payload) > 0 {
+		if err := json.Unmarshal(env.Payload, &p); err != nil {
+			s.fail(env.ID, "bad_request", "payload inválido")
+			return
+		}
+	}
+	if p.Room == "" {
+		s.fail(env.ID, "bad_request", "room é obrigatório")
+		return
+	}
+
+	var result any
+	var err error
+	switch env.Type {
+	case cmdJoin:
+		err = s.hub.Join(s.client, p.Room)
+		result = roomPayload{Room: p.Room}
+	case cmdLeave:
+		err = s.hub.Leave(s.client, p.Room)
+		result = roomPayload{Room: p.Room}
+	case cmdSend:
+		result, err = s.hub.Send(s.client, p.Room, p.Content)
+	case cmdEdit:
+		result, err = s.hub.Edit(s.client, p.Room, p.MessageID, p.Content)
+	case cmdDelete:
+		result, err = s.hub.Delete(s.client, p.Room, p.MessageID)
+	case cmdTyping:
+		err = s.hub.Typing(s.client, p.Room)
+	case cmdAck:
+		err = s.hub.MarkRead(s.client, p.Room, p.MessageID)
+	default:
+		s.fail(env.ID, "unknown_type", "comando desconhecido: "+env.Type)
+		return
+	}
+
+	if err != nil {
+		code := errorCode(err)
+		if code == "internal" {
+			log.Printf("Erro no comando %s: %v", env.Type, err)
+			s.fail(env.ID, code, "erro interno")
+			return
+		}
+		s.fail(env.ID, code, err.Error())
+		return
+	}
+
+	if env.ID != "" {
+		s.reply(s.frame(frameAck, env.ID, result))
+	}
+}
+
+// welcome é escrito direto na conexão, antes dos pumps iniciarem, para ser
+// sempre o primeiro frame
+func (s *session) welcome() error {
+	return s.writeReply(s.frame(frameWelcome, "", welcomePayload{
+		Protocol: SubprotocolV1,
+		UserID:   s.client.UserID,
+		Name:     s.client.Username,
+		Rooms:    s.client.Rooms(),
+	}))
+}
+
+// nameTaken avisa que o nome passou a outro usuário do cluster antes de a
+// conexão ser encerrada
+func (s *session) nameTaken() {
+	if !s.v1 {
+		s.writeMessage(domain.Message{
+			Type:      "system",
+			Username:  service.SystemUsername,
+			Content:   service.ErrNameInUse.Error(),
+			CreatedAt: time.Now(),
+		})
+		return
+	}
+	s.writeReply(s.frame(frameError, "", errorPayload{Code: "name_in_use", Message: service.ErrNameInUse.Error()}))
+}
+
+func (s *session) fail(id, code, message string) {
+	s.reply(s.frame(frameError, id, errorPayload{Code: code, Message: message}))
+}
+
+// reply enfileira uma resposta; descarta se a conexão já foi encerrada
+func (s *session) reply(env envelope) {
+	select {
+	case s.replies <- env:
+	case <-s.done:
+	}
+}
+
+func (s *session) frame(kind, id string, payload any) envelope {
+	env := envelope{V: protocolVersion, Type: kind, ID: id}
+	if payload != nil {
+		env.Payload, _ = json.Marshal(payload)
+	}
+	return env
+}
+
+// writeMessage envia um evento de sala no formato da conexão
+func (s *session) writeMessage(msg domain.Message) error {
+	s.conn.SetWriteDeadline(time.Now().Add(writeWait))
+
+	if !s.v1 {
+		// O formato antigo só conhece mensagens e avisos
+		switch msg.Type {
+		case "text", "join", "leave", "system":
+			return s.conn.WriteJSON(msg)
+		}
+		return nil
+	}
+
+	kind := frameMessage
+	switch msg.Type {
+	case "edit":
+		kind = frameEdited
+	case "delete":
+		kind = frameDeleted
+	case "typing":
+		kind = frameTyping
+	case "read":
+		kind = frameRead
+	}
+	return s.conn.WriteJSON(s.frame(kind, "", msg))
+}
+
+func (s *session) writeReply(env envelope) error {
+	s.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return s.conn.WriteJSON(env)
+}
+
+// errorCode traduz os erros do hub para os códigos dos frames de erro
+func errorCode(err error) string {
+	switch {
+	case errors.Is(err, service.ErrRoomNotFound):
+		return "room_not_found"
+	case errors.Is(err, service.ErrNotInRoom):
+		return "not_in_room"
+	case errors.Is(err, service.ErrEmptyMessage), errors.Is(err, service.ErrMessageTooLong):
+		return "invalid_message"
+	case errors.Is(err, service.ErrMessageNotFound):
+		return "not_found"
+	case errors.Is(err, service.ErrNotAuthor):
+		return "forbidden"
+	default:
+		return "internal"
+	}
+}
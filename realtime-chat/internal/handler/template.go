@@ -106,6 +106,16 @@ const HTMLTemplate = `<!DOCTYPE html>
             background: #667eea;
             color: white;
         }
+        .typing {
+            padding: 0 20px;
+            min-height: 18px;
+            font-size: 0.75rem;
+            color: #718096;
+        }
+        .message.deleted .message-content {
+            font-style: italic;
+            opacity: 0.7;
+        }
         .input-area {
             padding: 20px;
             background: white;
@@ -194,8 +204,9 @@ const HTMLTemplate = `<!DOCTYPE html>
                 <span class="user-count" id="userCount">0 online</span>
             </div>
             <div class="messages" id="messages"></div>
+            <div class="typing" id="typing"></div>
             <div class="input-area">
-                <input type="text" id="messageInput" placeholder="Digite sua mensagem..." maxlength="500" onkeypress="if(event.key==='Enter')sendMessage()">
+                <input type="text" id="messageInput" placeholder="Digite sua mensagem..." maxlength="500" onkeypress="if(event.key==='Enter')sendMessage()" oninput="notifyTyping()">
                 <button onclick="sendMessage()">Enviar</button>
             </div>
         </div>
@@ -204,8 +215,15 @@ const HTMLTemplate = `<!DOCTYPE html>
     <script>
         let ws;
         let username;
+        let userId;
+        let token;
         let currentRoom = 'general';
         let reconnectInterval;
+        let nextBefore = null; // cursor da página anterior do histórico
+        let loadingOlder = false;
+        let commandSeq = 0;
+        let lastTyping = 0;
+        const typingUsers = {};
 
         async function connect() {
             username = document.getElementById('usernameInput').value.trim();
@@ -216,6 +234,26 @@ const HTMLTemplate = `<!DOCTYPE html>
                 return;
             }
 
+            // Obter token de convidado; o servidor valida e reserva o nome
+            try {
+                const response = await fetch('/api/auth/guest', {
+                    method: 'POST',
+                    headers: {'Content-Type': 'application/json'},
+                    body: JSON.stringify({name: username})
+                });
+                if (!response.ok) {
+                    alert(await response.text());
+                    return;
+                }
+                const data = await response.json();
+                token = data.token;
+                userId = data.user_id;
+                username = data.name;
+            } catch (err) {
+                alert('Erro ao autenticar: ' + err);
+                return;
+            }
+
             currentRoom = room;
             
             // Carregar salas disponíveis
@@ -234,17 +272,14 @@ const HTMLTemplate = `<!DOCTYPE html>
 
         function connectWebSocket() {
             const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
-            ws = new WebSocket(protocol + '//' + window.location.host + '/ws?room=' + currentRoom + '&username=' + encodeURIComponent(username));
+            ws = new WebSocket(protocol + '//' + window.location.host + '/ws?room=' + encodeURIComponent(currentRoom) + '&token=' + encodeURIComponent(token), ['chat.v1']);
 
             ws.onopen = () => {
                 console.log('Conectado!');
                 clearInterval(reconnectInterval);
             };
 
-            ws.onmessage = (event) => {
-                const msg = JSON.parse(event.data);
-                displayMessage(msg);
-            };
+            ws.onmessage = (event) => handleFrame(JSON.parse(event.data));
 
             ws.onclose = () => {
                 console.log('Desconectado, tentando reconectar...');
@@ -256,6 +291,58 @@ const HTMLTemplate = `<!DOCTYPE html>
             };
         }
 
+        // Envia um comando do protocolo chat.v1; com id o servidor responde ack ou error
+        function sendCommand(type, payload, withId = true) {
+            if (!ws || ws.readyState !== WebSocket.OPEN) return false;
+            const frame = {v: 1, type: type, payload: payload};
+            if (withId) frame.id = String(++commandSeq);
+            ws.send(JSON.stringify(frame));
+            return true;
+        }
+
+        function handleFrame(frame) {
+            const msg = frame.payload;
+            switch (frame.type) {
+                case 'message':
+                    if (msg.room_id === currentRoom) displayMessage(msg);
+                    break;
+                case 'edited':
+                case 'deleted':
+                    if (msg.room_id === currentRoom) updateMessage(msg);
+                    break;
+                case 'typing':
+                    if (msg.room_id === currentRoom && msg.user_id !== userId) showTyping(msg.username);
+                    break;
+                case 'error':
+                    console.warn('Erro do servidor:', msg.code, msg.message);
+                    break;
+            }
+        }
+
+        function updateMessage(msg) {
+            const old = document.querySelector('.message[data-id="' + CSS.escape(msg.id) + '"]');
+            if (old) old.replaceWith(renderMessage(msg));
+        }
+
+        function notifyTyping() {
+            if (Date.now() - lastTyping < 3000) return;
+            lastTyping = Date.now();
+            sendCommand('typing', {room: currentRoom}, false);
+        }
+
+        function showTyping(name) {
+            typingUsers[name] = Date.now();
+            renderTyping();
+            setTimeout(renderTyping, 4000);
+        }
+
+        function renderTyping() {
+            const names = Object.keys(typingUsers).filter(name => Date.now() - typingUsers[name] < 4000);
+            const text = names.length === 0 ? '' :
+                names.join(', ') + (names.length === 1 ? ' está digitando...' : ' estão digitando...');
+            document.getElementById('typing').textContent = text;
+        }
+
         async function loadRooms() {
             try {
                 const response = await fetch('/api/rooms');
@@ -277,27 +364,40 @@ const HTMLTemplate = `<!DOCTYPE html>
 
         async function loadHistory() {
             try {
-                const response = await fetch('/api/messages?room=' + currentRoom);
+                const response = await fetch('/api/messages?room=' + encodeURIComponent(currentRoom));
                 const data = await response.json();
                 document.getElementById('messages').innerHTML = '';
                 data.messages.forEach(msg => displayMessage(msg));
+                nextBefore = data.has_more ? data.next_before : null;
             } catch (err) {
                 console.error('Erro ao carregar histórico:', err);
             }
         }
 
-        function displayMessage(msg) {
-            const messagesDiv = document.getElementById('messages');
+        function renderMessage(msg) {
             const messageDiv = document.createElement('div');
             let className = 'message';
-            if (msg.username === 'Sistema') className += ' system';
-            if (msg.username === username) className += ' own';
+            if (msg.type === 'join' || msg.type === 'leave' || msg.type === 'system') className += ' system';
+            if (msg.user_id && msg.user_id === userId) className += ' own';
+            if (msg.deleted) className += ' deleted';
             messageDiv.className = className;
+            messageDiv.dataset.id = msg.id;
             
             const time = new Date(msg.created_at).toLocaleTimeString('pt-BR', {hour: '2-digit', minute:'2-digit'});
+            const edited = msg.edited_at && !msg.deleted ? ' (editada)' : '';
+            const content = msg.deleted ? 'mensagem apagada' : escapeHtml(msg.content);
             
-            messageDiv.innerHTML = '<div class="message-header"><span class="username">' + escapeHtml(msg.username) + '</span><span class="time">' + time + '</span></div><div class="message-content">' + escapeHtml(msg.content) + '</div>';
-            
+            messageDiv.innerHTML = '<div class="message-header"><span class="username">' + escapeHtml(msg.username) + '</span><span class="time">' + time + edited + '</span></div><div class="message-content">' + content + '</div>';
+            return messageDiv;
+        }
+
+        function displayMessage(msg, prepend) {
+            const messagesDiv = document.getElementById('messages');
+            const messageDiv = renderMessage(msg);
+            if (prepend) {
+                messagesDiv.insertBefore(messageDiv, messagesDiv.firstChild);
+                return;
+            }
             messagesDiv.appendChild(messageDiv);
             messagesDiv.scrollTop = messagesDiv.scrollHeight;
         }
@@ -308,7 +408,7 @@ const HTMLTemplate = `<!DOCTYPE html>
             
             if (!content || !ws || ws.readyState !== WebSocket.OPEN) return;
             
-            ws.send(JSON.stringify({content: content}));
+            sendCommand('send', {room: currentRoom, content: content});
             input.value = '';
         }
 
@@ -332,15 +432,43 @@ const HTMLTemplate = `<!DOCTYPE html>
             }
         }
 
+        // A mesma conexão sai da sala atual e entra na nova
         function switchRoom(roomId) {
             if (roomId === currentRoom) return;
+            sendCommand('leave', {room: currentRoom});
             currentRoom = roomId;
-            if (ws) ws.close();
-            connectWebSocket();
+            sendCommand('join', {room: currentRoom});
+            document.getElementById('currentRoomName').textContent = currentRoom;
+            document.getElementById('typing').textContent = '';
             loadHistory();
             loadRooms();
         }
 
+        // Ao rolar até o topo carrega a página anterior do histórico
+        async function loadOlder() {
+            if (!nextBefore || loadingOlder) return;
+            loadingOlder = true;
+            const room = currentRoom;
+            try {
+                const response = await fetch('/api/messages?room=' + encodeURIComponent(room) + '&before=' + encodeURIComponent(nextBefore));
+                if (!response.ok || room !== currentRoom) return;
+                const data = await response.json();
+                const messagesDiv = document.getElementById('messages');
+                const height = messagesDiv.scrollHeight;
+                data.messages.slice().reverse().forEach(msg => displayMessage(msg, true));
+                messagesDiv.scrollTop = messagesDiv.scrollHeight - height;
+                nextBefore = data.has_more ? data.next_before : null;
+            } catch (err) {
+                console.error('Erro ao carregar histórico:', err);
+            } finally {
+                loadingOlder = false;
+            }
+        }
+
+        document.getElementById('messages').addEventListener('scroll', (event) => {
+            if (event.target.scrollTop === 0) loadOlder();
+        });
+
         function escapeHtml(text) {
             const div = document.createElement('div');
             div.textContent = text;
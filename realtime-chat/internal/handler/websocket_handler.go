@@ -4,11 +4,14 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"realtime-chat/internal/auth"
 	"realtime-chat/internal/domain"
 	"realtime-chat/internal/service"
-	"time"
+	"slices"
+	"strings"
 
 	"github.com/gorilla/websocket"
 )
@@ -16,69 +19,115 @@ import (
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
+	Subprotocols:    []string{SubprotocolV1},
 	CheckOrigin: func(r *http.Request) bool {
 		return true // Permitir todas as origens em desenvolvimento
 	},
 }
 
 type WebSocketHandler struct {
-	hub *service.Hub
+	hub    *service.Hub
+	signer *auth.Signer
 }
 
-func NewWebSocketHandler(hub *service.Hub) *WebSocketHandler {
-	return &WebSocketHandler{hub: hub}
+func NewWebSocketHandler(hub *service.Hub, signer *auth.Signer) *WebSocketHandler {
+	return &WebSocketHandler{hub: hub, signer: signer}
 }
 
+// HandleWebSocket exige um token de acesso, em "Authorization: Bearer" ou,
+// nos navegadores (que não enviam cabeçalhos no WebSocket), em ?token=. O
+// usuário e o nome vêm do token, nunca da query.
+//
+// Com o subprotocolo chat.v1 a conexão entra e sai de salas por comandos
+// (veja protocol.go) e ?room= é opcional; sem subprotocolo vale o formato
+// antigo, preso à sala da query.
 func (h *WebSocketHandler) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
-	// Pegar parâmetros da query
-	roomID := r.URL.Query().Get("room")
-	username := r.URL.Query().Get("username")
+	claims, err := h.signer.Verify(tokenFromRequest(r))
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "Token de acesso inválido ou expirado", http.StatusUnauthorized)
+		return
+	}
 
-	if roomID == "" {
-		roomID = "general"
+	// Tokens emitidos por outros serviços também passam pelas regras de nome
+	username, err := service.ValidateDisplayName(claims.Name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	requested := websocket.Subprotocols(r)
+	v1 := slices.Contains(requested, SubprotocolV1)
+	if len(requested) > 0 && !v1 {
+		http.Error(w, "Subprotocolo não suportado; use "+SubprotocolV1, http.StatusBadRequest)
+		return
 	}
-	if username == "" {
-		username = "Anônimo"
+
+	roomID := r.URL.Query().Get("room")
+	if roomID == "" && !v1 {
+		roomID = "general"
 	}
 
 	// Verificar se sala existe
-	if h.hub.GetRoom(roomID) == nil {
+	if roomID != "" && h.hub.GetRoom(roomID) == nil {
 		http.Error(w, "Sala não encontrada", http.StatusNotFound)
 		return
 	}
 
+	// O nome fica reservado enquanto o usuário tiver conexões abertas
+	if err := h.hub.ClaimName(claims.Subject, username); err != nil {
+		switch {
+		case errors.Is(err, service.ErrNameInUse):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, service.ErrNamesUnavailable):
+			log.Printf("Erro ao reservar o nome: %v", err)
+			http.Error(w, service.ErrNamesUnavailable.Error(), http.StatusServiceUnavailable)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
 	// Upgrade para WebSocket
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
+		h.hub.ReleaseName(claims.Subject, username)
 		log.Printf("Erro no upgrade WebSocket: %v", err)
 		return
 	}
 
-	client := &domain.Client{
-		ID:       generateID(),
-		Username: username,
-		RoomID:   roomID,
-		Send:     make(chan domain.Message, 256),
-	}
+	client := domain.NewClient(generateID(), claims.Subject, username)
+	s := newSession(h.hub, client, conn)
+	s.nameLost = h.hub.NameLost(claims.Subject, username)
 
-	// Registrar no hub
-	h.hub.GetRegisterChan() <- client
+	if roomID != "" {
+		if err := h.hub.Join(client, roomID); err != nil {
+			log.Printf("Erro ao entrar na sala %s: %v", roomID, err)
+		}
+	}
+	if s.v1 {
+		if err := s.welcome(); err != nil {
+			log.Printf("Erro ao enviar boas-vindas: %v", err)
+		}
+	}
 
 	// Iniciar goroutines
-	go h.writePump(client, conn)
-	go h.readPump(client, conn)
+	go h.writePump(s)
+	go h.readPump(s, roomID)
 }
 
-func (h *WebSocketHandler) readPump(client *domain.Client, conn *websocket.Conn) {
+// readPump lê os frames até a conexão cair; no formato antigo cada frame é
+// uma mensagem para a sala da query
+func (h *WebSocketHandler) readPump(s *session, legacyRoom string) {
 	defer func() {
-		h.hub.GetUnregisterChan() <- client
-		conn.Close()
+		h.hub.GetUnregisterChan() <- s.client
+		s.conn.Close()
 	}()
 
-	conn.SetReadLimit(512 * 1024) // 512KB max message size
+	s.conn.SetReadLimit(maxFrameSize)
 
 	for {
-		_, message, err := conn.ReadMessage()
+		_, message, err := s.conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("Erro de leitura WebSocket: %v", err)
@@ -86,39 +135,43 @@ func (h *WebSocketHandler) readPump(client *domain.Client, conn *websocket.Conn)
 			break
 		}
 
+		if s.v1 {
+			s.handle(message)
+			continue
+		}
+
 		var msgData struct {
 			Content string `json:"content"`
 		}
-		
 		if err := json.Unmarshal(message, &msgData); err != nil {
 			continue
 		}
-
-		msg := domain.Message{
-			ID:        generateID(),
-			RoomID:    client.RoomID,
-			Username:  client.Username,
-			Content:   msgData.Content,
-			Type:      "text",
-			CreatedAt: time.Now(),
-		}
-
-		// Enviar diretamente para o broadcast do hub
-		h.hub.GetBroadcastChan() <- msg
+		h.hub.Send(s.client, legacyRoom, msgData.Content)
 	}
 }
 
-func (h *WebSocketHandler) writePump(client *domain.Client, conn *websocket.Conn) {
-	defer conn.Close()
+// writePump envia os eventos das salas e as respostas aos comandos; termina
+// quando o hub fecha client.Send ou o nome do usuário passa a outro usuário
+func (h *WebSocketHandler) writePump(s *session) {
+	defer close(s.done)
+	defer s.conn.Close()
 
-	for msg := range client.Send {
-		data, err := json.Marshal(msg)
-		if err != nil {
-			continue
+	for {
+		var err error
+		select {
+		case msg, ok := <-s.client.Send:
+			if !ok {
+				return
+			}
+			err = s.writeMessage(msg)
+		case env := <-s.replies:
+			err = s.writeReply(env)
+		case <-s.nameLost:
+			s.nameTaken()
+			return
 		}
-
-		if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
-			break
+		if err != nil {
+			return
 		}
 	}
 }
@@ -127,4 +180,12 @@ func generateID() string {
 	bytes := make([]byte, 8)
 	rand.Read(bytes)
 	return hex.EncodeToString(bytes)
-}
\ No newline at end of file
+}
+
+// tokenFromRequest lê o token do cabeçalho Authorization ou do parâmetro token
+func tokenFromRequest(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		return strings.TrimPrefix(header, "Bearer ")
+	}
+	return r.URL.Query().Get("token")
+}
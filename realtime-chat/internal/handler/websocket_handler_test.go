@@ -0,0 +1,248 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"realtime-chat/internal/auth"
+	"realtime-chat/internal/broker"
+	"realtime-chat/internal/domain"
+	"realtime-chat/internal/repository"
+	"realtime-chat/internal/service"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+type testServer struct {
+	*httptest.Server
+	signer *auth.Signer
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	repo, err := repository.NewMessageRepository(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := broker.NewMemoryBroker()
+	t.Cleanup(func() { b.Close() })
+
+	hub := service.NewHub(repo, b)
+	go hub.Run()
+	// A sala geral é criada pelo loop do hub
+	deadline := time.Now().Add(5 * time.Second)
+	for hub.GetRoom("general") == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("sala geral não foi criada")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	signer := auth.NewSigner([]byte("segredo"), time.Hour)
+	srv := httptest.NewServer(http.HandlerFunc(NewWebSocketHandler(hub, signer).HandleWebSocket))
+	t.Cleanup(srv.Close)
+	return &testServer{Server: srv, signer: signer}
+}
+
+// dial conecta como o usuário pedindo os subprotocolos informados
+func (s *testServer) dial(t *testing.T, userID, name, query string, subprotocols ...string) (*websocket.Conn, *http.Response, error) {
+	t.Helper()
+	token, _, err := s.signer.Issue(userID, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := "ws" + strings.TrimPrefix(s.URL, "http") + "/ws?token=" + token + query
+	dialer := websocket.Dialer{Subprotocols: subprotocols, HandshakeTimeout: 5 * time.Second}
+	conn, resp, err := dialer.Dial(url, nil)
+	if err == nil {
+		t.Cleanup(func() { conn.Close() })
+	}
+	return conn, resp, err
+}
+
+func (s *testServer) dialV1(t *testing.T, userID, name, query string) *websocket.Conn {
+	t.Helper()
+	conn, _, err := s.dial(t, userID, name, query, SubprotocolV1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conn.Subprotocol() != SubprotocolV1 {
+		t.Fatalf("subprotocolo negociado %q, esperado %s", conn.Subprotocol(), SubprotocolV1)
+	}
+	if env := readFrame(t, conn); env.Type != frameWelcome {
+		t.Fatalf("primeiro frame %q, esperado welcome", env.Type)
+	}
+	return conn
+}
+
+func readFrame(t *testing.T, conn *websocket.Conn) envelope {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var env envelope
+	if err := conn.ReadJSON(&env); err != nil {
+		t.Fatalf("leitura do frame: %v", err)
+	}
+	return env
+}
+
+// command envia o comando e retorna a resposta com o mesmo id, ignorando os
+// eventos das salas que chegarem antes
+func command(t *testing.T, conn *websocket.Conn, v int, kind, id string, payload any) envelope {
+	t.Helper()
+	env := envelope{V: v, Type: kind, ID: id}
+	if payload != nil {
+		env.Payload, _ = json.Marshal(payload)
+	}
+	if err := conn.WriteJSON(env); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		reply := readFrame(t, conn)
+		if reply.ID == id {
+			return reply
+		}
+	}
+}
+
+func expectAck(t *testing.T, reply envelope, v any) {
+	t.Helper()
+	if reply.Type != frameAck {
+		t.Fatalf("resposta ao comando %s: %s %s, esperado ack", reply.ID, reply.Type, reply.Payload)
+	}
+	if v != nil {
+		if err := json.Unmarshal(reply.Payload, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func expectError(t *testing.T, reply envelope, code string) {
+	t.Helper()
+	var p errorPayload
+	json.Unmarshal(reply.Payload, &p)
+	if reply.Type != frameError || p.Code != code {
+		t.Errorf("resposta ao comando %s: %s %s, esperado erro %s", reply.ID, reply.Type, reply.Payload, code)
+	}
+}
+
+func TestWebSocketWelcomeIsFirstFrame(t *testing.T) {
+	srv := newTestServer(t)
+	conn, _, err := srv.dial(t, "u1", "Ana", "&room=general", SubprotocolV1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env := readFrame(t, conn)
+	if env.Type != frameWelcome || env.V != protocolVersion {
+		t.Fatalf("primeiro frame %q (v%d), esperado welcome", env.Type, env.V)
+	}
+	var welcome welcomePayload
+	if err := json.Unmarshal(env.Payload, &welcome); err != nil {
+		t.Fatal(err)
+	}
+	if welcome.Protocol != SubprotocolV1 || welcome.UserID != "u1" || welcome.Name != "Ana" || !slices.Equal(welcome.Rooms, []string{"general"}) {
+		t.Errorf("welcome = %+v", welcome)
+	}
+}
+
+func TestWebSocketV1Commands(t *testing.T) {
+	srv := newTestServer(t)
+	conn := srv.dialV1(t, "u1", "Ana", "")
+	general := commandPayload{Room: "general"}
+
+	var joined roomPayload
+	expectAck(t, command(t, conn, 1, cmdJoin, "j1", general), &joined)
+	if joined.Room != "general" {
+		t.Errorf("join confirmou a sala %q", joined.Room)
+	}
+
+	var sent domain.Message
+	expectAck(t, command(t, conn, 1, cmdSend, "s1", commandPayload{Room: "general", Content: "oi"}), &sent)
+	if sent.ID == "" || sent.Content != "oi" || sent.UserID != "u1" {
+		t.Fatalf("send confirmou %+v", sent)
+	}
+
+	var edited domain.Message
+	expectAck(t, command(t, conn, 1, cmdEdit, "e1", commandPayload{Room: "general", MessageID: sent.ID, Content: "olá"}), &edited)
+	if edited.ID != sent.ID || edited.Content != "olá" {
+		t.Errorf("edit confirmou %+v", edited)
+	}
+
+	expectAck(t, command(t, conn, 1, cmdTyping, "t1", general), nil)
+	expectAck(t, command(t, conn, 1, cmdAck, "a1", commandPayload{Room: "general", MessageID: sent.ID}), nil)
+
+	// Sem v o comando é aceito como a versão atual
+	if pong := command(t, conn, 0, cmdPing, "p1", nil); pong.Type != framePong {
+		t.Errorf("ping respondido com %s", pong.Type)
+	}
+
+	var deleted domain.Message
+	expectAck(t, command(t, conn, 1, cmdDelete, "d1", commandPayload{Room: "general", MessageID: sent.ID}), &deleted)
+	if !deleted.Deleted || deleted.Content != "" {
+		t.Errorf("delete confirmou %+v", deleted)
+	}
+
+	expectAck(t, command(t, conn, 1, cmdLeave, "l1", general), nil)
+	expectError(t, command(t, conn, 1, cmdSend, "s2", commandPayload{Room: "general", Content: "oi"}), "not_in_room")
+}
+
+func TestWebSocketV1Errors(t *testing.T) {
+	srv := newTestServer(t)
+	ana := srv.dialV1(t, "u1", "Ana", "&room=general")
+	bia := srv.dialV1(t, "u2", "Bia", "&room=general")
+
+	var sent domain.Message
+	expectAck(t, command(t, ana, 1, cmdSend, "s1", commandPayload{Room: "general", Content: "oi"}), &sent)
+
+	expectError(t, command(t, bia, 1, cmdEdit, "e1", commandPayload{Room: "general", MessageID: sent.ID, Content: "x"}), "forbidden")
+	expectError(t, command(t, bia, 1, cmdDelete, "d1", commandPayload{Room: "general", MessageID: sent.ID}), "forbidden")
+	expectError(t, command(t, bia, 1, "shout", "x1", commandPayload{Room: "general"}), "unknown_type")
+	expectError(t, command(t, bia, 2, cmdPing, "v2", nil), "unsupported_version")
+	expectError(t, command(t, bia, 1, cmdJoin, "j1", commandPayload{Room: "inexistente"}), "room_not_found")
+	expectError(t, command(t, bia, 1, cmdJoin, "j2", nil), "bad_request")
+}
+
+func TestWebSocketRejectsUnknownSubprotocol(t *testing.T) {
+	srv := newTestServer(t)
+	_, resp, err := srv.dial(t, "u1", "Ana", "", "chat.v2")
+	if err == nil {
+		t.Fatal("conexão aceita com subprotocolo desconhecido")
+	}
+	if resp == nil || resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("resposta %v, esperado 400", resp)
+	}
+}
+
+func TestWebSocketLegacyClient(t *testing.T) {
+	srv := newTestServer(t)
+	conn, _, err := srv.dial(t, "u1", "Ana", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conn.Subprotocol() != "" {
+		t.Fatalf("subprotocolo %q negociado sem pedido", conn.Subprotocol())
+	}
+
+	// Sem subprotocolo a conexão entra na sala geral e troca mensagens puras
+	if err := conn.WriteJSON(map[string]string{"content": "oi"}); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		var msg domain.Message
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("leitura: %v", err)
+		}
+		if msg.Type != "text" {
+			continue // aviso de entrada
+		}
+		if msg.Content != "oi" || msg.RoomID != "general" || msg.Username != "Ana" {
+			t.Errorf("mensagem recebida %+v", msg)
+		}
+		break
+	}
+}
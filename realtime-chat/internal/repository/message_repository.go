@@ -1,18 +1,46 @@
 package repository
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"log"
 	"os"
 	"path/filepath"
-	"sort"
-	"sync"
 	"realtime-chat/internal/domain"
+	"regexp"
+	"sync"
+)
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 200
 )
 
+var (
+	ErrCursorNotFound  = errors.New("mensagem do cursor não encontrada na sala")
+	ErrMessageNotFound = errors.New("mensagem não encontrada na sala")
+)
+
+// IDs de sala usados diretamente como nome de diretório
+var safeRoomID = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// MessageRepository guarda o histórico completo de cada sala em um log
+// append-only segmentado (veja roomLog). Em memória ficam só o índice das
+// mensagens e as mais recentes de cada sala aberta.
 type MessageRepository struct {
-	mu       sync.RWMutex
-	dataDir  string
-	messages map[string][]domain.Message // roomID -> messages
+	mu      sync.Mutex // protege rooms; a abertura de cada sala é feita fora dela
+	dataDir string
+	rooms   map[string]*openRoom
+}
+
+// openRoom é o log de uma sala, aberto uma única vez; quem pede a sala
+// durante a abertura espera em once
+type openRoom struct {
+	once sync.Once
+	log  *roomLog
+	err  error
 }
 
 func NewMessageRepository(dataDir string) (*MessageRepository, error) {
@@ -20,82 +48,124 @@ func NewMessageRepository(dataDir string) (*MessageRepository, error) {
 		return nil, err
 	}
 
-	repo := &MessageRepository{
-		dataDir:  dataDir,
-		messages: make(map[string][]domain.Message),
-	}
-
-	// Carregar mensagens existentes
-	files, err := os.ReadDir(dataDir)
-	if err == nil {
-		for _, file := range files {
-			if filepath.Ext(file.Name()) == ".json" {
-				roomID := file.Name()[:len(file.Name())-5]
-				repo.loadRoomMessages(roomID)
-			}
-		}
-	}
-
-	return repo, nil
+	return &MessageRepository{
+		dataDir: dataDir,
+		rooms:   make(map[string]*openRoom),
+	}, nil
 }
 
+// Save acrescenta a mensagem ao histórico da sala; IDs já gravados são
+// ignorados
 func (r *MessageRepository) Save(roomID string, msg domain.Message) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	r.messages[roomID] = append(r.messages[roomID], msg)
-	
-	// Manter apenas últimas 100 mensagens na memória
-	if len(r.messages[roomID]) > 100 {
-		r.messages[roomID] = r.messages[roomID][len(r.messages[roomID])-100:]
+	room, err := r.room(roomID, true)
+	if err != nil {
+		return err
 	}
+	return room.append(msg)
+}
 
-	return r.persist(roomID)
+// Find retorna a mensagem da sala com as edições aplicadas
+func (r *MessageRepository) Find(roomID, id string) (domain.Message, error) {
+	room, err := r.room(roomID, false)
+	if err != nil {
+		return domain.Message{}, err
+	}
+	if room == nil {
+		return domain.Message{}, ErrMessageNotFound
+	}
+	return room.find(id)
 }
 
-func (r *MessageRepository) GetRecent(roomID string, limit int) ([]domain.Message, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+// ApplyChange grava uma edição ou remoção ("edit" ou "delete") de uma
+// mensagem do histórico. Aplicar a mesma alteração de novo não faz nada;
+// editar uma mensagem apagada retorna ErrMessageNotFound.
+func (r *MessageRepository) ApplyChange(roomID string, change domain.Message) error {
+	room, err := r.room(roomID, false)
+	if err != nil {
+		return err
+	}
+	if room == nil {
+		return ErrMessageNotFound
+	}
+	return room.applyChange(change)
+}
 
-	msgs := r.messages[roomID]
-	if len(msgs) == 0 {
-		// Tentar carregar do disco
-		r.mu.RUnlock()
-		r.loadRoomMessages(roomID)
-		r.mu.RLock()
-		msgs = r.messages[roomID]
+// History retorna até limit mensagens anteriores à mensagem before (as mais
+// recentes quando before é vazio), da mais antiga para a mais nova, e se há
+// mensagens mais antigas
+func (r *MessageRepository) History(roomID, before string, limit int) ([]domain.Message, bool, error) {
+	room, err := r.room(roomID, false)
+	if err != nil {
+		return nil, false, err
 	}
+	if room == nil {
+		if before != "" {
+			return nil, false, ErrCursorNotFound
+		}
+		return []domain.Message{}, false, nil
+	}
+	return room.history(before, limit)
+}
+
+// room abre o log da sala. Sem create, salas sem histórico em disco
+// retornam nil para que consultas não encham o mapa de salas inexistentes.
+// A indexação dos segmentos acontece fora de r.mu, para que uma sala grande
+// não trave as demais enquanto abre.
+func (r *MessageRepository) room(roomID string, create bool) (*roomLog, error) {
+	dir := filepath.Join(r.dataDir, roomDirName(roomID))
+	legacy := r.legacyPath(roomID)
 
-	// Retornar as mais recentes
-	if len(msgs) <= limit {
-		result := make([]domain.Message, len(msgs))
-		copy(result, msgs)
-		return result, nil
+	r.mu.Lock()
+	room, ok := r.rooms[roomID]
+	if !ok {
+		if !create && !exists(dir) && !exists(legacy) {
+			r.mu.Unlock()
+			return nil, nil
+		}
+		room = &openRoom{}
+		r.rooms[roomID] = room
 	}
+	r.mu.Unlock()
 
-	result := make([]domain.Message, limit)
-	copy(result, msgs[len(msgs)-limit:])
-	
-	// Ordenar por data
-	sort.Slice(result, func(i, j int) bool {
-		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	room.once.Do(func() {
+		room.log, room.err = openRoomLog(dir)
+		if room.err != nil {
+			return
+		}
+		if err := migrateLegacy(legacy, room.log); err != nil {
+			log.Printf("⚠️  Erro ao migrar histórico antigo %s: %v", legacy, err)
+		}
 	})
-	
-	return result, nil
+
+	if room.err != nil {
+		// A próxima chamada tenta abrir de novo
+		r.mu.Lock()
+		if r.rooms[roomID] == room {
+			delete(r.rooms, roomID)
+		}
+		r.mu.Unlock()
+		return nil, room.err
+	}
+	return room.log, nil
 }
 
-func (r *MessageRepository) persist(roomID string) error {
-	filePath := filepath.Join(r.dataDir, roomID+".json")
-	data, err := json.MarshalIndent(r.messages[roomID], "", "  ")
-	if err != nil {
-		return err
+// legacyPath é o arquivo do formato anterior (um JSON com as últimas 100
+// mensagens da sala)
+func (r *MessageRepository) legacyPath(roomID string) string {
+	if !safeRoomID.MatchString(roomID) {
+		return ""
 	}
-	return os.WriteFile(filePath, data, 0644)
+	return filepath.Join(r.dataDir, roomID+".json")
 }
 
-func (r *MessageRepository) loadRoomMessages(roomID string) error {
-	filePath := filepath.Join(r.dataDir, roomID+".json")
-	data, err := os.ReadFile(filePath)
+// migrateLegacy importa o arquivo do formato anterior para um log vazio e o
+// renomeia para .migrated
+func migrateLegacy(path string, room *roomLog) error {
+	if path == "" || room.next > 0 {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil
@@ -107,8 +177,30 @@ func (r *MessageRepository) loadRoomMessages(roomID string) error {
 	if err := json.Unmarshal(data, &msgs); err != nil {
 		return err
 	}
+	for _, msg := range msgs {
+		if err := room.append(msg); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("📦 %d mensagem(ns) migrada(s) de %s", len(msgs), path)
+	return os.Rename(path, path+".migrated")
+}
 
-	r.messages[roomID] = msgs
-	return nil
+// roomDirName usa o ID da sala como diretório quando ele é seguro; os
+// demais viram um hash
+func roomDirName(roomID string) string {
+	if safeRoomID.MatchString(roomID) {
+		return roomID
+	}
+	sum := sha256.Sum256([]byte(roomID))
+	return "~" + hex.EncodeToString(sum[:16])
 }
 
+func exists(path string) bool {
+	if path == "" {
+		return false
+	}
+	_, err := os.Stat(path)
+	return err == nil
+}
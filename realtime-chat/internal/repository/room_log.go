@@ -0,0 +1,466 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"realtime-chat/internal/domain"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	segmentExt      = ".log"
+	changesFile     = "changes.log" // edições e remoções, aplicadas na leitura
+	maxSegmentSize  = 4 << 20       // novo segmento ao passar de 4 MB
+	recentCacheSize = 200           // mensagens mais recentes mantidas em memória
+)
+
+// roomLog é o histórico de uma sala: um diretório com segmentos append-only
+// (um JSON por linha) nomeados pelo número da primeira mensagem. Cada
+// mensagem recebe um número sequencial na sala; o índice guarda o número de
+// cada ID e a posição de cada linha no arquivo, para ler qualquer página sem
+// percorrer o log. Edições e remoções ficam em changes.log e são aplicadas
+// sobre as mensagens na leitura, sem reescrever os segmentos.
+type roomLog struct {
+	mu         sync.Mutex
+	dir        string
+	segments   []*segment
+	ids        map[string]uint64 // ID da mensagem -> número
+	next       uint64            // número da próxima mensagem
+	recent     []logEntry        // últimas mensagens, servem a primeira página
+	recentFrom uint64            // recent cobre os números a partir deste
+	file       *os.File          // último segmento, aberto para escrita
+	changes    map[string]messageChange
+}
+
+// logEntry é uma mensagem com o seu número na sala. Linhas inválidas não
+// viram entradas, então o número não pode ser deduzido da posição.
+type logEntry struct {
+	seq uint64
+	msg domain.Message
+}
+
+// messageChange é o estado atual de uma mensagem editada ou apagada
+type messageChange struct {
+	ID       string     `json:"id"`
+	Content  string     `json:"content,omitempty"`
+	EditedAt *time.Time `json:"edited_at,omitempty"`
+	Deleted  bool       `json:"deleted,omitempty"`
+}
+
+type segment struct {
+	path    string
+	first   uint64  // número da primeira mensagem
+	offsets []int64 // início de cada linha no arquivo
+	size    int64
+}
+
+// end é o número seguinte ao da última mensagem do segmento
+func (s *segment) end() uint64 {
+	return s.first + uint64(len(s.offsets))
+}
+
+// openRoomLog carrega o índice dos segmentos do diretório; o diretório só é
+// criado na primeira gravação
+func openRoomLog(dir string) (*roomLog, error) {
+	l := &roomLog{dir: dir, ids: make(map[string]uint64), changes: make(map[string]messageChange)}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		first, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		l.segments = append(l.segments, &segment{path: filepath.Join(dir, name), first: first})
+	}
+	sort.Slice(l.segments, func(i, j int) bool {
+		return l.segments[i].first < l.segments[j].first
+	})
+
+	for i, seg := range l.segments {
+		if err := l.load(seg, i == len(l.segments)-1); err != nil {
+			return nil, err
+		}
+		l.next = max(l.next, seg.end())
+	}
+
+	if err := l.loadChanges(); err != nil {
+		return nil, err
+	}
+
+	// Cache vazio até a leitura inicial, que vem dos segmentos
+	l.recentFrom = l.next
+	from := l.next - min(l.next, recentCacheSize)
+	l.recent, err = l.entries(from, l.next)
+	if err != nil {
+		return nil, err
+	}
+	l.recentFrom = from
+	return l, nil
+}
+
+// load indexa as linhas do segmento. Uma linha final sem quebra de linha é
+// uma escrita interrompida e é descartada no último segmento.
+func (l *roomLog) load(seg *segment, last bool) error {
+	f, err := os.Open(seg.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 && last {
+				log.Printf("⚠️  Escrita incompleta descartada em %s", seg.path)
+				if err := os.Truncate(seg.path, offset); err != nil {
+					return err
+				}
+			}
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		// Linhas inválidas mantêm o número, mas ficam fora do índice e das
+		// leituras
+		var head struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(line, &head); err != nil {
+			log.Printf("⚠️  Mensagem inválida em %s ignorada: %v", seg.path, err)
+		} else if head.ID != "" {
+			l.ids[head.ID] = seg.end()
+		}
+
+		seg.offsets = append(seg.offsets, offset)
+		offset += int64(len(line))
+	}
+
+	seg.size = offset
+	return nil
+}
+
+// loadChanges lê as edições; a última linha de cada mensagem prevalece
+func (l *roomLog) loadChanges() error {
+	f, err := os.Open(filepath.Join(l.dir, changesFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var change messageChange
+		if err := json.Unmarshal(scanner.Bytes(), &change); err != nil {
+			// Provavelmente uma escrita interrompida
+			log.Printf("⚠️  Alteração inválida em %s ignorada: %v", l.dir, err)
+			continue
+		}
+		l.changes[change.ID] = change
+	}
+	return scanner.Err()
+}
+
+func (l *roomLog) applyChange(msg domain.Message) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.ids[msg.ID]; !ok {
+		return ErrMessageNotFound
+	}
+
+	// Remoção é definitiva; repetir a remoção não é erro
+	current := l.changes[msg.ID]
+	if current.Deleted {
+		if msg.Type == "delete" {
+			return nil
+		}
+		return ErrMessageNotFound
+	}
+
+	change := messageChange{ID: msg.ID}
+	switch msg.Type {
+	case "edit":
+		change.Content = msg.Content
+		change.EditedAt = msg.EditedAt
+	case "delete":
+		change.Deleted = true
+	default:
+		return fmt.Errorf("alteração desconhecida: %q", msg.Type)
+	}
+
+	// A mesma alteração chega de novo quando volta pelo broker
+	if current.Content == change.Content && current.EditedAt != nil && change.EditedAt != nil && current.EditedAt.Equal(*change.EditedAt) {
+		return nil
+	}
+
+	data, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(l.dir, changesFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("erro ao gravar alteração: %w", err)
+	}
+
+	l.changes[msg.ID] = change
+	return nil
+}
+
+// overlay aplica a edição ou remoção registrada para a mensagem
+func (l *roomLog) overlay(msg domain.Message) domain.Message {
+	change, ok := l.changes[msg.ID]
+	if !ok {
+		return msg
+	}
+	if change.Deleted {
+		msg.Content = ""
+		msg.Deleted = true
+		return msg
+	}
+	msg.Content = change.Content
+	msg.EditedAt = change.EditedAt
+	return msg
+}
+
+func (l *roomLog) find(id string) (domain.Message, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	seq, ok := l.ids[id]
+	if !ok {
+		return domain.Message{}, ErrMessageNotFound
+	}
+	msgs, err := l.read(seq, seq+1)
+	if err != nil {
+		return domain.Message{}, err
+	}
+	if len(msgs) == 0 {
+		return domain.Message{}, ErrMessageNotFound
+	}
+	return msgs[0], nil
+}
+
+func (l *roomLog) append(msg domain.Message) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// A mesma mensagem pode chegar mais de uma vez pelo broker
+	if _, dup := l.ids[msg.ID]; dup && msg.ID != "" {
+		return nil
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	seg, err := l.writable(int64(len(data)))
+	if err != nil {
+		return err
+	}
+	if _, err := l.file.Write(data); err != nil {
+		// Desfaz uma escrita parcial para não corromper a linha seguinte
+		l.file.Truncate(seg.size)
+		return fmt.Errorf("erro ao gravar histórico: %w", err)
+	}
+
+	seg.offsets = append(seg.offsets, seg.size)
+	seg.size += int64(len(data))
+	if msg.ID != "" {
+		l.ids[msg.ID] = l.next
+	}
+	l.next++
+
+	l.recent = append(l.recent, logEntry{seq: l.next - 1, msg: msg})
+	if len(l.recent) > 2*recentCacheSize {
+		l.recent = append([]logEntry(nil), l.recent[len(l.recent)-recentCacheSize:]...)
+		l.recentFrom = l.recent[0].seq
+	}
+	return nil
+}
+
+// writable retorna o segmento que recebe a próxima gravação, abrindo o
+// último ou criando um novo quando ele passaria do tamanho máximo
+func (l *roomLog) writable(n int64) (*segment, error) {
+	if len(l.segments) > 0 {
+		seg := l.segments[len(l.segments)-1]
+		if seg.size == 0 || seg.size+n <= maxSegmentSize {
+			if l.file == nil {
+				f, err := os.OpenFile(seg.path, os.O_WRONLY|os.O_APPEND, 0644)
+				if err != nil {
+					return nil, err
+				}
+				l.file = f
+			}
+			return seg, nil
+		}
+	}
+
+	if err := os.MkdirAll(l.dir, 0755); err != nil {
+		return nil, err
+	}
+	seg := &segment{
+		path:  filepath.Join(l.dir, fmt.Sprintf("%020d%s", l.next, segmentExt)),
+		first: l.next,
+	}
+	f, err := os.OpenFile(seg.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	if l.file != nil {
+		l.file.Close()
+	}
+	l.file = f
+	l.segments = append(l.segments, seg)
+	return seg, nil
+}
+
+func (l *roomLog) history(before string, limit int) ([]domain.Message, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	end := l.next
+	if before != "" {
+		seq, ok := l.ids[before]
+		if !ok {
+			return nil, false, ErrCursorNotFound
+		}
+		end = seq
+	}
+
+	// Uma entrada a mais só para saber se há mensagens mais antigas
+	entries, err := l.before(end, limit+1)
+	if err != nil {
+		return nil, false, err
+	}
+	hasMore := len(entries) > limit
+	if hasMore {
+		entries = entries[1:]
+	}
+
+	msgs := make([]domain.Message, 0, len(entries))
+	for _, entry := range entries {
+		msgs = append(msgs, l.overlay(entry.msg))
+	}
+	return msgs, hasMore, nil
+}
+
+// before retorna até n mensagens válidas com número menor que end, lendo
+// para trás até completar n ou chegar ao início do log: linhas inválidas
+// não ocupam lugar na página
+func (l *roomLog) before(end uint64, n int) ([]logEntry, error) {
+	first := l.next
+	if len(l.segments) > 0 {
+		first = l.segments[0].first
+	}
+
+	var entries []logEntry
+	for end > first && len(entries) < n {
+		start := end - min(end-first, uint64(n-len(entries)))
+		older, err := l.entries(start, end)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(older[:len(older):len(older)], entries...)
+		end = start
+	}
+	return entries, nil
+}
+
+// read retorna as mensagens com número em [start, end), com as edições
+// aplicadas; deve ser chamado com l.mu travado (ou antes do log ser
+// publicado)
+func (l *roomLog) read(start, end uint64) ([]domain.Message, error) {
+	entries, err := l.entries(start, end)
+	if err != nil {
+		return nil, err
+	}
+	msgs := make([]domain.Message, 0, len(entries))
+	for _, entry := range entries {
+		msgs = append(msgs, l.overlay(entry.msg))
+	}
+	return msgs, nil
+}
+
+// entries retorna as mensagens válidas com número em [start, end), do cache
+// quando possível, sem as edições
+func (l *roomLog) entries(start, end uint64) ([]logEntry, error) {
+	if start >= l.recentFrom {
+		i := sort.Search(len(l.recent), func(i int) bool { return l.recent[i].seq >= start })
+		j := sort.Search(len(l.recent), func(i int) bool { return l.recent[i].seq >= end })
+		return l.recent[i:j:j], nil
+	}
+
+	entries := make([]logEntry, 0, end-start)
+	for _, seg := range l.segments {
+		lo, hi := max(start, seg.first), min(end, seg.end())
+		if lo >= hi {
+			continue
+		}
+
+		from := seg.offsets[lo-seg.first]
+		to := seg.size
+		if hi < seg.end() {
+			to = seg.offsets[hi-seg.first]
+		}
+
+		data, err := readSection(seg.path, from, to)
+		if err != nil {
+			return nil, err
+		}
+		for i, line := range bytes.Split(bytes.TrimSuffix(data, []byte("\n")), []byte("\n")) {
+			var msg domain.Message
+			if json.Unmarshal(line, &msg) == nil {
+				entries = append(entries, logEntry{seq: lo + uint64(i), msg: msg})
+			}
+		}
+	}
+	return entries, nil
+}
+
+func readSection(path string, from, to int64) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data := make([]byte, to-from)
+	if _, err := f.ReadAt(data, from); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return data, nil
+}
@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"realtime-chat/internal/domain"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// writeSegment grava um segmento com n linhas; as linhas em bad ficam
+// inválidas e as demais são a mensagem "m<número>"
+func writeSegment(t *testing.T, dir string, n int, bad ...int) {
+	t.Helper()
+	invalid := make(map[int]bool)
+	for _, i := range bad {
+		invalid[i] = true
+	}
+
+	var b strings.Builder
+	for i := range n {
+		if invalid[i] {
+			b.WriteString("{\"id\":\"corrompida\n")
+			continue
+		}
+		data, err := json.Marshal(domain.Message{ID: fmt.Sprintf("m%d", i), RoomID: "sala", Type: "text", Content: fmt.Sprint(i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.Write(append(data, '\n'))
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("%020d%s", 0, segmentExt))
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func ids(msgs []domain.Message) string {
+	ids := make([]string, len(msgs))
+	for i, msg := range msgs {
+		ids[i] = msg.ID
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestRoomLogSkipsInvalidLinesWithoutShifting(t *testing.T) {
+	dir := t.TempDir()
+	// Uma linha inválida fora do cache e outra dentro dele
+	total := recentCacheSize + 50
+	writeSegment(t, dir, total, 10, total-3)
+
+	l, err := openRoomLog(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.file.Close()
+
+	// Cada ID aponta para a própria mensagem, no cache e nos segmentos
+	for _, i := range []int{0, 9, 11, 100, total - 4, total - 2, total - 1} {
+		id := fmt.Sprintf("m%d", i)
+		msg, err := l.find(id)
+		if err != nil {
+			t.Fatalf("find(%s): %v", id, err)
+		}
+		if msg.ID != id {
+			t.Errorf("find(%s) retornou %s", id, msg.ID)
+		}
+	}
+
+	// As linhas inválidas não ocupam lugar na página
+	tests := []struct {
+		before string
+		limit  int
+		want   string
+	}{
+		{"", 4, fmt.Sprintf("m%d,m%d,m%d,m%d", total-5, total-4, total-2, total-1)},
+		{fmt.Sprintf("m%d", total-2), 3, fmt.Sprintf("m%d,m%d,m%d", total-6, total-5, total-4)},
+		{"m12", 4, "m7,m8,m9,m11"},
+		{"m2", 5, "m0,m1"},
+	}
+	for _, tt := range tests {
+		msgs, _, err := l.history(tt.before, tt.limit)
+		if err != nil {
+			t.Fatalf("history(%q, %d): %v", tt.before, tt.limit, err)
+		}
+		if got := ids(msgs); got != tt.want {
+			t.Errorf("history(%q, %d) = %s, esperado %s", tt.before, tt.limit, got, tt.want)
+		}
+	}
+
+	// Mensagens novas entram no cache com o número seguinte
+	if err := l.append(domain.Message{ID: "nova", RoomID: "sala", Type: "text"}); err != nil {
+		t.Fatal(err)
+	}
+	msgs, _, err := l.history("nova", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ids(msgs), fmt.Sprintf("m%d,m%d", total-2, total-1); got != want {
+		t.Errorf("history antes da nova = %s, esperado %s", got, want)
+	}
+	if msg, err := l.find("nova"); err != nil || msg.ID != "nova" {
+		t.Errorf("find(nova) = %s, %v", msg.ID, err)
+	}
+}
+
+func TestRoomLogCacheTrimKeepsNumbers(t *testing.T) {
+	dir := t.TempDir()
+	writeSegment(t, dir, 5, 2)
+
+	l, err := openRoomLog(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.file.Close()
+
+	// Passa do limite do cache para forçar o corte
+	for i := 5; i < 5+2*recentCacheSize+1; i++ {
+		if err := l.append(domain.Message{ID: fmt.Sprintf("m%d", i), RoomID: "sala", Type: "text"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if l.recentFrom == 0 {
+		t.Fatal("cache não foi cortado")
+	}
+
+	for _, i := range []int{1, 3, int(l.recentFrom) - 1, int(l.recentFrom), 5 + 2*recentCacheSize} {
+		id := fmt.Sprintf("m%d", i)
+		if msg, err := l.find(id); err != nil || msg.ID != id {
+			t.Errorf("find(%s) = %s, %v", id, msg.ID, err)
+		}
+	}
+	msgs, _, err := l.history("m4", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(msgs); got != "m0,m1,m3" {
+		t.Errorf("history(m4, 3) = %s, esperado m0,m1,m3", got)
+	}
+}
+
+func TestRoomLogHasMoreIgnoresInvalidLines(t *testing.T) {
+	dir := t.TempDir()
+	// Só linhas inválidas antes de m3
+	writeSegment(t, dir, 6, 0, 1, 2)
+
+	l, err := openRoomLog(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.file.Close()
+
+	tests := []struct {
+		before  string
+		limit   int
+		want    string
+		hasMore bool
+	}{
+		{"", 2, "m4,m5", true},
+		{"", 3, "m3,m4,m5", false},
+		{"m4", 1, "m3", false},
+		{"m3", 2, "", false},
+	}
+	for _, tt := range tests {
+		msgs, hasMore, err := l.history(tt.before, tt.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ids(msgs); got != tt.want || hasMore != tt.hasMore {
+			t.Errorf("history(%q, %d) = %s (mais: %v), esperado %s (mais: %v)", tt.before, tt.limit, got, hasMore, tt.want, tt.hasMore)
+		}
+	}
+}
+
+func TestRepositoryOpensRoomOnce(t *testing.T) {
+	repo, err := NewMessageRepository(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Gravações simultâneas na sala que ainda não foi aberta
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := repo.Save("sala", domain.Message{ID: fmt.Sprintf("m%d", i), RoomID: "sala", Type: "text"}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	msgs, hasMore, err := repo.History("sala", "", 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 20 || hasMore {
+		t.Errorf("%d mensagens (mais: %v), esperado 20", len(msgs), hasMore)
+	}
+}
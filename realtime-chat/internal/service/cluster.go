@@ -0,0 +1,93 @@
+package service
+
+import (
+	"encoding/json"
+	"log"
+	"realtime-chat/internal/broker"
+	"realtime-chat/internal/domain"
+	"time"
+)
+
+// Intervalo entre os pedidos de salas enquanto o broker não responde
+const syncRetryInterval = time.Second
+
+// Tipos de evento trocados entre os nós pelo broker
+const (
+	eventMessage = "message" // mensagem para os clientes da sala
+	eventRoom    = "room"    // sala criada em algum nó
+	eventSync    = "sync"    // nó iniciando pede as salas existentes
+)
+
+type hubEvent struct {
+	Node    string          `json:"node"`
+	Kind    string          `json:"kind"`
+	Message *domain.Message `json:"message,omitempty"`
+	Room    *roomEvent      `json:"room,omitempty"`
+}
+
+type roomEvent struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// publish envia o evento a todos os nós, inclusive este
+func (h *Hub) publish(event hubEvent) error {
+	if h.broker == nil {
+		return broker.ErrUnavailable
+	}
+
+	event.Node = h.nodeID
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return h.broker.Publish(payload)
+}
+
+func (h *Hub) publishRoom(room *domain.Room) {
+	err := h.publish(hubEvent{
+		Kind: eventRoom,
+		Room: &roomEvent{ID: room.ID, Name: room.Name, Description: room.Description},
+	})
+	if err != nil {
+		log.Printf("⚠️  Sala %s não anunciada aos outros nós: %v", room.ID, err)
+	}
+}
+
+// handleEvent trata um evento recebido do broker
+func (h *Hub) handleEvent(payload []byte) {
+	var event hubEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		log.Printf("⚠️  Evento inválido no broker: %v", err)
+		return
+	}
+
+	switch event.Kind {
+	case eventMessage:
+		if event.Message != nil {
+			h.deliver(*event.Message)
+		}
+
+	case eventRoom:
+		if event.Node != h.nodeID && event.Room != nil && event.Room.ID != "" {
+			h.addRoom(event.Room.ID, event.Room.Name, event.Room.Description)
+		}
+
+	case eventSync:
+		if event.Node == h.nodeID {
+			h.synced = true
+			return
+		}
+		h.roomsMu.RLock()
+		rooms := make([]*domain.Room, 0, len(h.rooms))
+		for _, room := range h.rooms {
+			rooms = append(rooms, room)
+		}
+		h.roomsMu.RUnlock()
+
+		for _, room := range rooms {
+			h.publishRoom(room)
+		}
+	}
+}
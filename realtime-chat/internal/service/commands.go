@@ -0,0 +1,231 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"realtime-chat/internal/domain"
+	"realtime-chat/internal/repository"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const maxMessageLength = 4000 // caracteres
+
+var (
+	ErrRoomNotFound    = errors.New("sala não encontrada")
+	ErrNotInRoom       = errors.New("a conexão não está nessa sala")
+	ErrEmptyMessage    = errors.New("mensagem vazia")
+	ErrMessageTooLong  = fmt.Errorf("mensagem com mais de %d caracteres", maxMessageLength)
+	ErrMessageNotFound = errors.New("mensagem não encontrada")
+	ErrNotAuthor       = errors.New("só o autor pode alterar a mensagem")
+)
+
+// Join coloca a conexão na sala e anuncia a entrada. Entrar de novo numa
+// sala em que a conexão já está não faz nada.
+func (h *Hub) Join(client *domain.Client, roomID string) error {
+	room := h.GetRoom(roomID)
+	if room == nil {
+		return ErrRoomNotFound
+	}
+	if !client.JoinRoom(roomID) {
+		return nil
+	}
+
+	room.AddClient(client)
+	h.broadcast <- systemMessage(roomID, "join", client.Username+" entrou na sala")
+
+	log.Printf("👤 %s entrou na sala %s", client.Username, roomID)
+	return nil
+}
+
+// Leave tira a conexão da sala e anuncia a saída
+func (h *Hub) Leave(client *domain.Client, roomID string) error {
+	leaveMsg, ok := h.removeFromRoom(client, roomID)
+	if !ok {
+		return ErrNotInRoom
+	}
+	h.broadcast <- leaveMsg
+	return nil
+}
+
+// removeFromRoom retorna o aviso de saída, ou false se a conexão não
+// estava na sala
+func (h *Hub) removeFromRoom(client *domain.Client, roomID string) (domain.Message, bool) {
+	if !client.LeaveRoom(roomID) {
+		return domain.Message{}, false
+	}
+	if room := h.GetRoom(roomID); room != nil {
+		room.RemoveClient(client)
+	}
+
+	log.Printf("👋 %s saiu da sala %s", client.Username, roomID)
+	return systemMessage(roomID, "leave", client.Username+" saiu da sala"), true
+}
+
+// Send grava a mensagem de texto no histórico deste nó, publica na sala e
+// a retorna com o ID atribuído. A confirmação só sai depois de gravada; a
+// cópia que volta pelo broker é ignorada pelo histórico.
+func (h *Hub) Send(client *domain.Client, roomID, content string) (domain.Message, error) {
+	if !client.InRoom(roomID) {
+		return domain.Message{}, ErrNotInRoom
+	}
+	if err := validateContent(content); err != nil {
+		return domain.Message{}, err
+	}
+
+	msg := domain.Message{
+		ID:        generateID(),
+		RoomID:    roomID,
+		UserID:    client.UserID,
+		Username:  client.Username,
+		Content:   content,
+		Type:      "text",
+		CreatedAt: time.Now(),
+	}
+	if err := h.msgRepo.Save(roomID, msg); err != nil {
+		return domain.Message{}, err
+	}
+	h.broadcast <- msg
+	return msg, nil
+}
+
+// Edit troca o conteúdo de uma mensagem do próprio usuário
+func (h *Hub) Edit(client *domain.Client, roomID, messageID, content string) (domain.Message, error) {
+	if err := validateContent(content); err != nil {
+		return domain.Message{}, err
+	}
+	original, err := h.ownMessage(client, roomID, messageID)
+	if err != nil {
+		return domain.Message{}, err
+	}
+
+	now := time.Now()
+	change := original
+	change.Content = content
+	change.Type = "edit"
+	change.EditedAt = &now
+
+	if err := h.applyChange(change); err != nil {
+		return domain.Message{}, err
+	}
+	h.broadcast <- change
+	return change, nil
+}
+
+// Delete apaga uma mensagem do próprio usuário; o histórico a mantém como
+// apagada, sem o conteúdo
+func (h *Hub) Delete(client *domain.Client, roomID, messageID string) (domain.Message, error) {
+	original, err := h.ownMessage(client, roomID, messageID)
+	if err != nil {
+		return domain.Message{}, err
+	}
+
+	change := original
+	change.Content = ""
+	change.Type = "delete"
+	change.Deleted = true
+
+	if err := h.applyChange(change); err != nil {
+		return domain.Message{}, err
+	}
+	h.broadcast <- change
+	return change, nil
+}
+
+// Typing avisa a sala que o usuário está digitando; não vai para o histórico
+func (h *Hub) Typing(client *domain.Client, roomID string) error {
+	if !client.InRoom(roomID) {
+		return ErrNotInRoom
+	}
+
+	h.broadcast <- domain.Message{
+		ID:        generateID(),
+		RoomID:    roomID,
+		UserID:    client.UserID,
+		Username:  client.Username,
+		Type:      "typing",
+		CreatedAt: time.Now(),
+	}
+	return nil
+}
+
+// MarkRead avisa a sala que o usuário leu as mensagens até messageID
+func (h *Hub) MarkRead(client *domain.Client, roomID, messageID string) error {
+	if !client.InRoom(roomID) {
+		return ErrNotInRoom
+	}
+	if _, err := h.msgRepo.Find(roomID, messageID); err != nil {
+		if errors.Is(err, repository.ErrMessageNotFound) {
+			return ErrMessageNotFound
+		}
+		return err
+	}
+
+	h.broadcast <- domain.Message{
+		ID:        messageID,
+		RoomID:    roomID,
+		UserID:    client.UserID,
+		Username:  client.Username,
+		Type:      "read",
+		CreatedAt: time.Now(),
+	}
+	return nil
+}
+
+// applyChange grava a alteração neste nó antes de anunciá-la, para que uma
+// edição logo após a remoção já seja recusada; os outros nós gravam ao
+// receber o evento
+func (h *Hub) applyChange(change domain.Message) error {
+	if err := h.msgRepo.ApplyChange(change.RoomID, change); err != nil {
+		if errors.Is(err, repository.ErrMessageNotFound) {
+			return ErrMessageNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// ownMessage busca uma mensagem não apagada do usuário na sala
+func (h *Hub) ownMessage(client *domain.Client, roomID, messageID string) (domain.Message, error) {
+	if !client.InRoom(roomID) {
+		return domain.Message{}, ErrNotInRoom
+	}
+
+	msg, err := h.msgRepo.Find(roomID, messageID)
+	if err != nil {
+		if errors.Is(err, repository.ErrMessageNotFound) {
+			return domain.Message{}, ErrMessageNotFound
+		}
+		return domain.Message{}, err
+	}
+	if msg.Deleted {
+		return domain.Message{}, ErrMessageNotFound
+	}
+	if msg.UserID != client.UserID {
+		return domain.Message{}, ErrNotAuthor
+	}
+	return msg, nil
+}
+
+func validateContent(content string) error {
+	if strings.TrimSpace(content) == "" {
+		return ErrEmptyMessage
+	}
+	if utf8.RuneCountInString(content) > maxMessageLength {
+		return ErrMessageTooLong
+	}
+	return nil
+}
+
+func systemMessage(roomID, kind, content string) domain.Message {
+	return domain.Message{
+		ID:        generateID(),
+		RoomID:    roomID,
+		Username:  SystemUsername,
+		Content:   content,
+		Type:      kind,
+		CreatedAt: time.Now(),
+	}
+}
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"realtime-chat/internal/broker"
+	"realtime-chat/internal/domain"
+	"realtime-chat/internal/repository"
+	"testing"
+)
+
+func TestSendSavesBeforeAck(t *testing.T) {
+	repo, err := repository.NewMessageRepository(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := broker.NewMemoryBroker()
+	t.Cleanup(func() { b.Close() })
+	hub := NewHub(repo, b)
+
+	// Sem o loop do hub rodando: a mensagem precisa estar gravada no retorno
+	client := domain.NewClient("c1", "u1", "Ana")
+	client.JoinRoom("general")
+	msg, err := hub.Send(client, "general", "oi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Find("general", msg.ID); err != nil {
+		t.Fatalf("mensagem confirmada não está no histórico: %v", err)
+	}
+
+	// A cópia que volta pelo broker não duplica o histórico
+	hub.deliver(<-hub.broadcast)
+	msgs, _, err := repo.History("general", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 1 {
+		t.Errorf("%d mensagens no histórico, esperado 1", len(msgs))
+	}
+}
@@ -3,113 +3,144 @@ package service
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"log"
+	"realtime-chat/internal/broker"
 	"realtime-chat/internal/domain"
 	"realtime-chat/internal/repository"
 	"sync"
 	"time"
 )
 
+// Hub mantém as salas e os clientes conectados a este nó. As mensagens
+// passam pelo broker para chegar aos clientes de todos os nós e os nomes em
+// uso são reservados no registro do broker; a contagem de usuários das
+// salas continua local a cada nó.
 type Hub struct {
 	rooms      map[string]*domain.Room
 	roomsMu    sync.RWMutex
 	msgRepo    *repository.MessageRepository
-	register   chan *domain.Client
 	unregister chan *domain.Client
 	broadcast  chan domain.Message
+
+	names    map[string]*nameClaim // nomes de exibição dos usuários conectados
+	namesMu  sync.Mutex
+	registry broker.Registry // reservas de nomes entre os nós; nil sem broker
+
+	broker broker.Broker
+	nodeID string        // identifica os eventos publicados por este nó
+	events <-chan []byte // eventos de todos os nós, inclusive deste
+	synced bool          // o pedido de salas deste nó já voltou pelo broker
 }
 
-func NewHub(msgRepo *repository.MessageRepository) *Hub {
-	return &Hub{
+// NewHub cria o hub ligado ao broker; nil usa um broker em memória, para um
+// único nó
+func NewHub(msgRepo *repository.MessageRepository, b broker.Broker) *Hub {
+	if b == nil {
+		b = broker.NewMemoryBroker()
+	}
+
+	h := &Hub{
 		rooms:      make(map[string]*domain.Room),
 		msgRepo:    msgRepo,
-		register:   make(chan *domain.Client),
 		unregister: make(chan *domain.Client),
 		broadcast:  make(chan domain.Message, 256),
+		names:      make(map[string]*nameClaim),
+		broker:     b,
+		nodeID:     generateID(),
+	}
+
+	if registry, ok := b.(broker.Registry); ok {
+		h.registry = registry
 	}
+
+	events, err := b.Subscribe()
+	if err != nil {
+		log.Printf("⚠️  Falha na inscrição no broker, mensagens ficam restritas a este nó: %v", err)
+		h.broker = nil
+	}
+	h.events = events
+	return h
 }
 
 func (h *Hub) Run() {
 	// Criar sala geral por padrão
-	h.CreateRoom("general", "Geral", "Sala de bate-papo geral")
+	h.addRoom("general", "Geral", "Sala de bate-papo geral")
+
+	if h.registry != nil {
+		go h.renewNames()
+	}
+
+	// Pedir aos outros nós as salas criadas antes deste iniciar, repetindo
+	// até o pedido voltar pelo broker (a conexão pode ainda não estar pronta)
+	syncTicker := time.NewTicker(syncRetryInterval)
+	defer syncTicker.Stop()
+	syncTick := syncTicker.C
+	h.publish(hubEvent{Kind: eventSync})
 
 	for {
+		if h.synced || h.broker == nil {
+			syncTick = nil
+		}
+
 		select {
-		case client := <-h.register:
-			h.handleRegister(client)
+		case <-syncTick:
+			h.publish(hubEvent{Kind: eventSync})
 
 		case client := <-h.unregister:
 			h.handleUnregister(client)
 
 		case message := <-h.broadcast:
 			h.handleBroadcast(message)
+
+		case payload, ok := <-h.events:
+			if !ok {
+				h.events = nil
+				continue
+			}
+			h.handleEvent(payload)
 		}
 	}
 }
 
-func (h *Hub) handleRegister(client *domain.Client) {
-	room := h.GetRoom(client.RoomID)
-	if room == nil {
-		close(client.Send)
-		return
+// handleUnregister tira a conexão encerrada de todas as salas e libera o
+// nome do usuário
+func (h *Hub) handleUnregister(client *domain.Client) {
+	for _, roomID := range client.Rooms() {
+		if leaveMsg, ok := h.removeFromRoom(client, roomID); ok {
+			// Direto, sem passar pelo canal que este mesmo loop consome
+			h.handleBroadcast(leaveMsg)
+		}
 	}
 
-	room.AddClient(client)
-	
-	// Notificar entrada
-	joinMsg := domain.Message{
-		ID:        generateID(),
-		RoomID:    client.RoomID,
-		Username:  "Sistema",
-		Content:   client.Username + " entrou na sala",
-		Type:      "join",
-		CreatedAt: time.Now(),
-	}
-	
-	h.broadcast <- joinMsg
-	
-	log.Printf("👤 %s entrou na sala %s", client.Username, client.RoomID)
+	client.Close()
+	h.ReleaseName(client.UserID, client.Username)
 }
 
-func (h *Hub) handleUnregister(client *domain.Client) {
-	room := h.GetRoom(client.RoomID)
-	if room == nil {
-		return
+func (h *Hub) handleBroadcast(message domain.Message) {
+	// A entrega aos clientes acontece quando o evento volta pelo broker;
+	// sem broker a mensagem ao menos chega aos clientes deste nó
+	if err := h.publish(hubEvent{Kind: eventMessage, Message: &message}); err != nil {
+		log.Printf("⚠️  Broker indisponível, mensagem entregue só neste nó: %v", err)
+		h.deliver(message)
 	}
+}
 
-	// Verificar se cliente existe na sala
-	clients := room.GetClients()
-	found := false
-	for _, c := range clients {
-		if c.ID == client.ID {
-			found = true
-			break
+// deliver persiste a mensagem e a envia aos clientes da sala neste nó
+func (h *Hub) deliver(message domain.Message) {
+	// Persistir mensagem; cada nó mantém o histórico no seu diretório e a
+	// gravação síncrona preserva a ordem de chegada. O nó de origem já gravou
+	// a mensagem em Send e a repetição é ignorada pelo ID.
+	switch message.Type {
+	case "text":
+		if err := h.msgRepo.Save(message.RoomID, message); err != nil {
+			log.Printf("⚠️  Erro ao salvar mensagem na sala %s: %v", message.RoomID, err)
 		}
-	}
-
-	if found {
-		room.RemoveClient(client)
-		
-		// Notificar saída
-		leaveMsg := domain.Message{
-			ID:        generateID(),
-			RoomID:    client.RoomID,
-			Username:  "Sistema",
-			Content:   client.Username + " saiu da sala",
-			Type:      "leave",
-			CreatedAt: time.Now(),
+	case "edit", "delete":
+		// Mensagem ausente aqui: o nó de origem já validou a alteração
+		if err := h.msgRepo.ApplyChange(message.RoomID, message); err != nil && !errors.Is(err, repository.ErrMessageNotFound) {
+			log.Printf("⚠️  Erro ao alterar mensagem %s na sala %s: %v", message.ID, message.RoomID, err)
 		}
-		
-		h.broadcast <- leaveMsg
-		
-		log.Printf("👋 %s saiu da sala %s", client.Username, client.RoomID)
-	}
-}
-
-func (h *Hub) handleBroadcast(message domain.Message) {
-	// Persistir mensagem
-	if message.Type == "text" {
-		go h.msgRepo.Save(message.RoomID, message)
 	}
 
 	room := h.GetRoom(message.RoomID)
@@ -119,11 +150,10 @@ func (h *Hub) handleBroadcast(message domain.Message) {
 
 	clients := room.GetClients()
 	for _, client := range clients {
-		select {
-		case client.Send <- message:
-		default:
-			// Cliente lento, remover
-			room.RemoveClient(client)
+		if !client.Deliver(message) {
+			// Cliente lento: encerrar a conexão; a saída das salas acontece
+			// no unregister feito pelo handler
+			client.Close()
 		}
 	}
 }
@@ -134,22 +164,32 @@ func (h *Hub) GetRoom(roomID string) *domain.Room {
 	return h.rooms[roomID]
 }
 
+// CreateRoom cria a sala neste nó e a anuncia aos demais
 func (h *Hub) CreateRoom(id, name, description string) *domain.Room {
+	room, created := h.addRoom(id, name, description)
+	if created {
+		h.publishRoom(room)
+	}
+	return room
+}
+
+// addRoom cria a sala só neste nó; retorna a existente se já houver
+func (h *Hub) addRoom(id, name, description string) (*domain.Room, bool) {
 	h.roomsMu.Lock()
 	defer h.roomsMu.Unlock()
 
 	if room, exists := h.rooms[id]; exists {
-		return room
+		return room, false
 	}
 
 	room := domain.NewRoom(id, name, description)
 	h.rooms[id] = room
-	
+
 	// Iniciar goroutine para broadcast da sala
 	go h.runRoomBroadcast(room)
-	
+
 	log.Printf("🏠 Sala criada: %s (%s)", name, id)
-	return room
+	return room, true
 }
 
 func (h *Hub) runRoomBroadcast(room *domain.Room) {
@@ -174,10 +214,6 @@ func (h *Hub) GetRooms() []domain.RoomInfo {
 	return rooms
 }
 
-func (h *Hub) GetRegisterChan() chan<- *domain.Client {
-	return h.register
-}
-
 func (h *Hub) GetUnregisterChan() chan<- *domain.Client {
 	return h.unregister
 }
@@ -190,4 +226,4 @@ func generateID() string {
 	bytes := make([]byte, 8)
 	rand.Read(bytes)
 	return hex.EncodeToString(bytes)
-}
\ No newline at end of file
+}
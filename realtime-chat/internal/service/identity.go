@@ -0,0 +1,252 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	SystemUsername = "Sistema"
+	maxNameLength  = 20
+
+	// Reservas de nome no broker: renovadas enquanto houver conexões, expiram
+	// sozinhas se o nó cair
+	nameClaimTTL      = 30 * time.Second
+	nameRenewInterval = 10 * time.Second
+)
+
+var (
+	ErrInvalidName  = errors.New("nome inválido: use de 1 a 20 caracteres visíveis")
+	ErrReservedName = errors.New("nome reservado")
+	ErrNameInUse    = errors.New("nome já está em uso por outro usuário conectado")
+
+	// ErrNamesUnavailable indica que o registro de nomes do broker não
+	// respondeu; sem ele não há como garantir que o nome está livre nos
+	// outros nós
+	ErrNamesUnavailable = errors.New("registro de nomes indisponível")
+)
+
+// Nomes que imitariam mensagens do servidor ou da administração
+var reservedNames = map[string]bool{
+	"sistema":       true,
+	"system":        true,
+	"admin":         true,
+	"administrador": true,
+	"moderador":     true,
+	"servidor":      true,
+	"anônimo":       true,
+}
+
+// ValidateDisplayName normaliza os espaços do nome e recusa nomes vazios,
+// longos demais, com caracteres de controle ou reservados
+func ValidateDisplayName(name string) (string, error) {
+	name = strings.Join(strings.Fields(name), " ")
+	if name == "" || utf8.RuneCountInString(name) > maxNameLength {
+		return "", ErrInvalidName
+	}
+	for _, r := range name {
+		if !unicode.IsPrint(r) {
+			return "", ErrInvalidName
+		}
+	}
+	if reservedNames[nameKey(name)] {
+		return "", ErrReservedName
+	}
+	return name, nil
+}
+
+// nameKey compara nomes sem diferenciar maiúsculas
+func nameKey(name string) string {
+	return strings.ToLower(name)
+}
+
+// registryKey é a chave da reserva do nome no broker
+func registryKey(key string) string {
+	return "name:" + key
+}
+
+// nameClaim é o dono de um nome de exibição e quantas conexões deste nó o
+// usam. A primeira conexão reserva o nome no broker; as seguintes esperam
+// ready e recebem o mesmo resultado em err. lost é fechado quando a
+// renovação encontra o nome reservado por outro usuário.
+type nameClaim struct {
+	userID      string
+	connections int
+	ready       chan struct{}
+	err         error
+	lost        chan struct{}
+}
+
+// NameAvailable indica se o nome está livre para o usuário neste nó e,
+// havendo registro no broker, nos demais. Retorna ErrNamesUnavailable se o
+// registro não responder.
+func (h *Hub) NameAvailable(userID, name string) (bool, error) {
+	key := nameKey(name)
+
+	h.namesMu.Lock()
+	claim, taken := h.names[key]
+	h.namesMu.Unlock()
+	if taken {
+		return claim.userID == userID && !claimLost(claim), nil
+	}
+
+	if h.registry == nil {
+		return true, nil
+	}
+	holder, err := h.registry.Holder(registryKey(key))
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", ErrNamesUnavailable, err)
+	}
+	return holder == "" || holder == userID, nil
+}
+
+// ClaimName reserva o nome para uma conexão do usuário. O mesmo usuário pode
+// abrir várias conexões (abas, salas, nós) com o mesmo nome. Com o registro
+// do broker fora do ar a reserva é recusada com ErrNamesUnavailable.
+func (h *Hub) ClaimName(userID, name string) error {
+	key := nameKey(name)
+
+	h.namesMu.Lock()
+	claim, taken := h.names[key]
+	if taken && (claim.userID != userID || claimLost(claim)) {
+		h.namesMu.Unlock()
+		return ErrNameInUse
+	}
+	if !taken {
+		claim = &nameClaim{userID: userID, ready: make(chan struct{}), lost: make(chan struct{})}
+		h.names[key] = claim
+	}
+	claim.connections++
+	h.namesMu.Unlock()
+
+	// Consulta ao broker fora da trava: só quem criou a reserva local pergunta
+	if taken {
+		<-claim.ready
+	} else {
+		claim.err = h.claimInRegistry(key, userID)
+		close(claim.ready)
+	}
+
+	if claim.err != nil {
+		h.ReleaseName(userID, name)
+		return claim.err
+	}
+	return nil
+}
+
+func (h *Hub) claimInRegistry(key, userID string) error {
+	if h.registry == nil {
+		return nil
+	}
+	ok, err := h.registry.Claim(registryKey(key), userID, nameClaimTTL)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrNamesUnavailable, err)
+	}
+	if !ok {
+		return ErrNameInUse
+	}
+	return nil
+}
+
+// NameLost retorna um canal fechado quando o nome do usuário passa a
+// pertencer a outro usuário no cluster (por exemplo depois de uma queda do
+// broker maior que a validade da reserva). As conexões devem então ser
+// encerradas.
+func (h *Hub) NameLost(userID, name string) <-chan struct{} {
+	h.namesMu.Lock()
+	defer h.namesMu.Unlock()
+
+	claim, taken := h.names[nameKey(name)]
+	if !taken || claim.userID != userID {
+		lost := make(chan struct{})
+		close(lost)
+		return lost
+	}
+	return claim.lost
+}
+
+func claimLost(claim *nameClaim) bool {
+	select {
+	case <-claim.lost:
+		return true
+	default:
+		return false
+	}
+}
+
+// ReleaseName libera o nome quando a última conexão do usuário fecha. A
+// remoção no broker acontece em segundo plano para não travar o hub.
+func (h *Hub) ReleaseName(userID, name string) {
+	h.namesMu.Lock()
+	defer h.namesMu.Unlock()
+
+	key := nameKey(name)
+	claim, taken := h.names[key]
+	if !taken || claim.userID != userID {
+		return
+	}
+	claim.connections--
+	if claim.connections > 0 {
+		return
+	}
+	delete(h.names, key)
+
+	// Uma reserva recusada ou perdida pertence a outro usuário
+	if h.registry != nil && claim.err == nil && !claimLost(claim) {
+		go func() {
+			if err := h.registry.Release(registryKey(key), userID); err != nil {
+				log.Printf("⚠️  Erro ao liberar o nome %q no broker: %v", name, err)
+			}
+		}()
+	}
+}
+
+// renewNames renova periodicamente no broker as reservas dos nomes em uso
+// neste nó e recupera as que outro nó do mesmo usuário liberou
+func (h *Hub) renewNames() {
+	ticker := time.NewTicker(nameRenewInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		h.renewClaims()
+	}
+}
+
+// renewClaims renova as reservas uma vez. Um nome que outro usuário
+// reservou enquanto a reserva deste nó estava vencida é perdido: lost é
+// fechado e as conexões do usuário neste nó são encerradas.
+func (h *Hub) renewClaims() {
+	h.namesMu.Lock()
+	claims := make(map[string]*nameClaim, len(h.names))
+	for key, claim := range h.names {
+		select {
+		case <-claim.ready:
+			if claim.err == nil && !claimLost(claim) {
+				claims[key] = claim
+			}
+		default: // reserva ainda em andamento
+		}
+	}
+	h.namesMu.Unlock()
+
+	for key, claim := range claims {
+		ok, err := h.registry.Claim(registryKey(key), claim.userID, nameClaimTTL)
+		if err != nil {
+			log.Printf("⚠️  Erro ao renovar as reservas de nomes: %v", err)
+			return
+		}
+		if !ok {
+			log.Printf("⚠️  Nome %q reservado por outro usuário em outro nó, encerrando as conexões", key)
+			h.namesMu.Lock()
+			if !claimLost(claim) {
+				close(claim.lost)
+			}
+			h.namesMu.Unlock()
+		}
+	}
+}
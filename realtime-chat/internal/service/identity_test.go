@@ -0,0 +1,182 @@
+package service
+
+import (
+	"errors"
+	"realtime-chat/internal/broker"
+	"realtime-chat/internal/repository"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestNodes cria hubs ligados ao mesmo broker, como nós de um cluster
+func newTestNodes(t *testing.T, n int) []*Hub {
+	t.Helper()
+	b := broker.NewMemoryBroker()
+	t.Cleanup(func() { b.Close() })
+
+	hubs := make([]*Hub, n)
+	for i := range hubs {
+		repo, err := repository.NewMessageRepository(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		hubs[i] = NewHub(repo, b)
+	}
+	return hubs
+}
+
+func TestClaimNameAcrossNodes(t *testing.T) {
+	nodes := newTestNodes(t, 2)
+	a, b := nodes[0], nodes[1]
+
+	if err := a.ClaimName("u1", "Ana"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.ClaimName("u2", "ana"); !errors.Is(err, ErrNameInUse) {
+		t.Errorf("nome em uso em outro nó: err = %v, esperado ErrNameInUse", err)
+	}
+	if available, _ := b.NameAvailable("u2", "ANA"); available {
+		t.Error("NameAvailable ignora a reserva de outro nó")
+	}
+	if available, _ := b.NameAvailable("u1", "Ana"); !available {
+		t.Error("nome indisponível para o próprio dono")
+	}
+
+	// O mesmo usuário pode usar o nome em vários nós e conexões
+	if err := b.ClaimName("u1", "Ana"); err != nil {
+		t.Errorf("mesmo usuário em outro nó: %v", err)
+	}
+	if err := a.ClaimName("u1", "Ana"); err != nil {
+		t.Errorf("segunda conexão do usuário: %v", err)
+	}
+
+	a.ReleaseName("u1", "Ana")
+	a.ReleaseName("u1", "Ana")
+	b.ReleaseName("u1", "Ana")
+
+	// A liberação no broker é assíncrona
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if available, _ := b.NameAvailable("u2", "Ana"); available {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("nome não foi liberado no broker")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := b.ClaimName("u2", "Ana"); err != nil {
+		t.Errorf("nome liberado recusado: %v", err)
+	}
+}
+
+func TestClaimNameConcurrentNodes(t *testing.T) {
+	nodes := newTestNodes(t, 4)
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(nodes))
+	for i, hub := range nodes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = hub.ClaimName(string(rune('a'+i)), "Bia")
+		}()
+	}
+	wg.Wait()
+
+	granted := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			granted++
+		case !errors.Is(err, ErrNameInUse):
+			t.Errorf("erro inesperado: %v", err)
+		}
+	}
+	if granted != 1 {
+		t.Errorf("%d usuários receberam o mesmo nome, esperado 1", granted)
+	}
+
+	// Quem foi recusado não mantém reserva local
+	for i, hub := range nodes {
+		if errs[i] != nil && len(hub.names) != 0 {
+			t.Errorf("nó %d manteve a reserva recusada", i)
+		}
+	}
+}
+
+func TestRenewalLosesNameTakenElsewhere(t *testing.T) {
+	nodes := newTestNodes(t, 2)
+	a, b := nodes[0], nodes[1]
+
+	if err := a.ClaimName("u1", "Ana"); err != nil {
+		t.Fatal(err)
+	}
+	lost := a.NameLost("u1", "Ana")
+
+	// A reserva de a venceu (broker fora do ar além da validade) e b
+	// entregou o nome a outro usuário
+	if err := a.registry.Release(registryKey("ana"), "u1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.ClaimName("u2", "Ana"); err != nil {
+		t.Fatal(err)
+	}
+
+	a.renewClaims()
+	select {
+	case <-lost:
+	default:
+		t.Fatal("renovação não sinalizou a perda do nome")
+	}
+	if err := a.ClaimName("u1", "Ana"); !errors.Is(err, ErrNameInUse) {
+		t.Errorf("nova conexão com o nome perdido: err = %v, esperado ErrNameInUse", err)
+	}
+
+	// A liberação das conexões antigas não remove a reserva de u2
+	a.ReleaseName("u1", "Ana")
+	time.Sleep(50 * time.Millisecond)
+	if available, _ := a.NameAvailable("u1", "Ana"); available {
+		t.Error("nome de u2 liberado pelas conexões de u1")
+	}
+}
+
+// downRegistry é um broker em memória cujo registro de nomes está fora do ar
+type downRegistry struct {
+	*broker.MemoryBroker
+}
+
+var errRegistryDown = errors.New("conexão recusada")
+
+func (downRegistry) Claim(key, owner string, ttl time.Duration) (bool, error) {
+	return false, errRegistryDown
+}
+
+func (downRegistry) Release(key, owner string) error {
+	return errRegistryDown
+}
+
+func (downRegistry) Holder(key string) (string, error) {
+	return "", errRegistryDown
+}
+
+func TestClaimNameFailsWithoutRegistry(t *testing.T) {
+	repo, err := repository.NewMessageRepository(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := downRegistry{broker.NewMemoryBroker()}
+	t.Cleanup(func() { b.Close() })
+	hub := NewHub(repo, b)
+
+	if err := hub.ClaimName("u1", "Ana"); !errors.Is(err, ErrNamesUnavailable) {
+		t.Errorf("ClaimName: err = %v, esperado ErrNamesUnavailable", err)
+	}
+	if len(hub.names) != 0 {
+		t.Error("nome reservado só neste nó com o registro fora do ar")
+	}
+	if _, err := hub.NameAvailable("u1", "Ana"); !errors.Is(err, ErrNamesUnavailable) {
+		t.Errorf("NameAvailable: err = %v, esperado ErrNamesUnavailable", err)
+	}
+}
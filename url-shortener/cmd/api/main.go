@@ -1,51 +1,253 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"url-shortener/internal/config"
 	"url-shortener/internal/handlers"
+	"url-shortener/internal/metrics"
+	"url-shortener/internal/middleware"
 	"url-shortener/internal/repository"
 	"url-shortener/internal/service"
 )
 
 func main() {
-	// Configurações
-	port := "8080"
-	domain := fmt.Sprintf("http://localhost:%s", port)
+	// Configurações: arquivo opcional (-config ou CONFIG_FILE) e variáveis de ambiente
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "arquivo de configuração YAML ou JSON")
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatal("Erro na configuração: ", err)
+	}
+	domain := cfg.BaseURL
 	
 	// Inicializar dependências
-	store := repository.NewMemoryStore()
+	store, err := newStore(cfg.Storage.Backend, cfg.Storage.Path)
+	if err != nil {
+		log.Fatal("Erro ao inicializar armazenamento:", err)
+	}
+	stopSweeper := func() {}
+	if sweeper, ok := store.(repository.Sweeper); ok {
+		stopSweeper = sweeper.StartSweeper(cfg.Storage.SweepInterval.Duration, cfg.Storage.ExpiredRetention.Duration)
+	}
 	urlService := service.NewURLService(store, domain)
+	codeGenerator, err := service.NewCodeGenerator(cfg.Codes.Strategy, cfg.Codes.Alphabet, cfg.Codes.Salt)
+	if err != nil {
+		log.Fatal(err)
+	}
+	urlService.SetCodeGenerator(codeGenerator)
+	urlService.SetCodeLength(cfg.Codes.Length)
+	for _, domain := range cfg.Domains {
+		if err := urlService.AddDomain(domain); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Cliques gravados em lote; o buffer é esvaziado no encerramento
+	clickRecorder := service.NewClickRecorder(
+		store,
+		cfg.Clicks.FlushInterval.Duration,
+		cfg.Clicks.BufferSize,
+	)
+	urlService.SetClickRecorder(clickRecorder)
+	if cfg.GeoIPFile != "" {
+		locator, err := service.LoadCIDRLocator(cfg.GeoIPFile)
+		if err != nil {
+			log.Fatal("Erro ao carregar tabela de países:", err)
+		}
+		urlService.SetIPLocator(locator)
+	}
+	blocklistService := service.NewBlocklistService(store)
+	urlService.SetBlocklist(blocklistService)
+	if !cfg.ResolveDestinations {
+		urlService.Validator().SetResolver(nil)
+	}
+	stopLinkChecker := func() {}
+	if cfg.LinkCheck.Enabled {
+		linkChecker := service.NewLinkChecker(store,
+			cfg.LinkCheck.Interval.Duration,
+			cfg.LinkCheck.Concurrency,
+			cfg.LinkCheck.Timeout.Duration,
+		)
+		stopLinkChecker = linkChecker.Start()
+	}
 	urlHandler := handlers.NewURLHandler(urlService)
+	linkHandler := handlers.NewLinkHandler(urlService)
+	accountService := service.NewAccountService(store)
+	accountHandler := handlers.NewAccountHandler(accountService)
+	adminHandler := handlers.NewAdminHandler(blocklistService, urlService)
+	requireAdmin := middleware.RequireAdmin(cfg.AdminToken)
+	healthHandler := handlers.NewHealthHandler(store)
+
+	// Tamanho do armazenamento em /metrics
+	if counter, ok := store.(interface{ Count() int }); ok {
+		metrics.Default.NewGaugeFunc("urlshortener_links", "Links armazenados.", func() float64 {
+			return float64(counter.Count())
+		})
+	}
+	metrics.Default.NewGaugeFunc("urlshortener_click_queue_length", "Cliques aguardando gravação.", func() float64 {
+		return float64(clickRecorder.Pending())
+	})
+
+	// Limites de requisições por cliente (0 desativa)
+	ipResolver, err := middleware.NewIPResolver(cfg.TrustedProxies)
+	if err != nil {
+		log.Fatal(err)
+	}
+	createLimiter := middleware.NewRateLimiter("create",
+		cfg.RateLimits.CreatePerMin,
+		cfg.RateLimits.CreateBurst,
+	)
+	redirectLimiter := middleware.NewRateLimiter("redirect",
+		cfg.RateLimits.RedirectPerMin,
+		cfg.RateLimits.RedirectBurst,
+	)
+	batchLimiter := middleware.NewRateLimiter("batch",
+		cfg.RateLimits.BatchRowsPerMin,
+		cfg.RateLimits.BatchRowsBurst,
+	)
+	urlHandler.SetBatchLimiter(batchLimiter)
+	urlHandler.SetMaxBatchRows(cfg.MaxBatchRows)
 	
 	// Configurar rotas
 	mux := http.NewServeMux()
 	
 	// Rota para criar URL curta
-	mux.HandleFunc("/shorten", urlHandler.CreateURL)
+	mux.HandleFunc("/shorten", middleware.Instrument("/shorten", createLimiter.Limit(urlHandler.CreateURL)))
+	mux.HandleFunc("/shorten/batch", middleware.Instrument("/shorten/batch", batchLimiter.Limit(urlHandler.CreateBatch)))
 	
 	// Rota para estatísticas
-	mux.HandleFunc("/stats/", urlHandler.GetStats)
+	mux.HandleFunc("/stats/", middleware.Instrument("/stats/{code}", urlHandler.GetStats))
+
+	// QR code da URL curta (PNG ou SVG)
+	mux.HandleFunc("/qr/", middleware.Instrument("/qr/{code}", redirectLimiter.Limit(urlHandler.GetQRCode)))
+
+	// Contas e chaves de API
+	mux.HandleFunc("/accounts", middleware.Instrument("/accounts", createLimiter.Limit(accountHandler.CreateAccount)))
+	mux.HandleFunc("/accounts/keys", middleware.Instrument("/accounts/keys", middleware.RequireAccount(accountHandler.CreateAPIKey)))
+
+	// Gerenciamento dos links da conta
+	mux.HandleFunc("/links", middleware.Instrument("/links", middleware.RequireAccount(linkHandler.ListLinks)))
+	mux.HandleFunc("/links/", middleware.Instrument("/links/{code}", middleware.RequireAccount(linkHandler.Link)))
+	mux.HandleFunc("/links/export", middleware.Instrument("/links/export", middleware.RequireAccount(linkHandler.Export)))
+	mux.HandleFunc("/links/broken", middleware.Instrument("/links/broken", middleware.RequireAccount(linkHandler.BrokenLinks)))
 	
+	// Administração (header X-Admin-Token)
+	mux.HandleFunc("/admin/blocklist", middleware.Instrument("/admin/blocklist", requireAdmin(adminHandler.Blocklist)))
+	mux.HandleFunc("/admin/keyspace", middleware.Instrument("/admin/keyspace", requireAdmin(adminHandler.Keyspace)))
+
+	// Monitoramento
+	mux.Handle("/metrics", metrics.Default)
+	mux.HandleFunc("/healthz", healthHandler.Healthz)
+	mux.HandleFunc("/readyz", healthHandler.Readyz)
+
 	// Rota para redirecionamento (deve ser a última)
-	mux.HandleFunc("/", urlHandler.RedirectURL)
+	mux.HandleFunc("/", middleware.Instrument("/{code}", redirectLimiter.Limit(urlHandler.RedirectURL)))
 	
-	// Middleware de logging
-	handler := loggingMiddleware(mux)
+	// Middlewares de logging, IP do cliente e autenticação por chave de API
+	handler := loggingMiddleware(middleware.APIKeyAuth(accountService)(mux))
+	handler = middleware.ClientIP(ipResolver)(handler)
 	
-	fmt.Printf("🚀 Servidor iniciado em %s\n", domain)
+	fmt.Printf("🚀 Servidor iniciado em %s (escutando em %s)\n", domain, cfg.ListenAddr)
+	if len(cfg.Domains) > 0 {
+		fmt.Printf("🏷️  Domínios adicionais: %s\n", strings.Join(cfg.Domains, ", "))
+	}
 	fmt.Println("📚 Endpoints disponíveis:")
 	fmt.Println("   POST /shorten     - Criar URL curta")
+	fmt.Println("   POST /shorten/batch - Criar URLs curtas em lote (JSON ou CSV)")
 	fmt.Println("   GET  /stats/{code}- Estatísticas da URL")
 	fmt.Println("   GET  /{code}      - Redirecionar para URL original")
+	fmt.Println("   GET  /{code}+     - Pré-visualizar o destino sem redirecionar")
+	fmt.Println("   GET  /qr/{code}   - QR code da URL curta (PNG ou SVG)")
+	fmt.Println("   POST /accounts    - Criar conta e chave de API")
+	fmt.Println("   GET  /links       - Listar links da conta")
+	fmt.Println("   GET  /links/export - Exportar links da conta (CSV ou NDJSON)")
+	fmt.Println("   GET  /links/broken - Links da conta com destino quebrado")
+	fmt.Println("   PATCH/DELETE /links/{code} - Alterar ou remover link da conta")
+	fmt.Println("   GET/POST/DELETE /admin/blocklist - Domínios bloqueados (admin)")
+	fmt.Println("   GET  /admin/keyspace - Ocupação do espaço de códigos (admin)")
+	fmt.Println("   GET  /metrics     - Métricas no formato Prometheus")
+	fmt.Println("   GET  /healthz, /readyz - Liveness e readiness")
 	
-	log.Fatal(http.ListenAndServe(":"+port, handler))
+	server := &http.Server{
+		Addr:    cfg.ListenAddr,
+		Handler: handler,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		var err error
+		if cfg.TLS.Enabled() {
+			err = server.ListenAndServeTLS(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	log.Println("🛑 Encerrando servidor...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout.Duration)
+	defer cancel()
+
+	// Para de receber tráfego, espera as requisições em andamento e só então
+	// grava os cliques pendentes e fecha o armazenamento
+	healthHandler.SetShuttingDown()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("⚠️  Erro ao encerrar servidor HTTP: %v", err)
+	}
+	if err := clickRecorder.Close(shutdownCtx); err != nil {
+		log.Printf("⚠️  Cliques pendentes não gravados: %v", err)
+	}
+	stopLinkChecker()
+	stopSweeper()
+	if closer, ok := store.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.Printf("⚠️  Erro ao fechar armazenamento: %v", err)
+		}
+	}
+	log.Println("👋 Servidor encerrado")
 }
 
 func loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("[%s] %s - %s", r.Method, r.URL.Path, r.RemoteAddr)
+		log.Printf("[%s] %s - %s", r.Method, r.URL.Path, middleware.GetClientIP(r))
 		next.ServeHTTP(w, r)
 	})
 }
+
+// storage reúne as interfaces implementadas pelos backends de armazenamento
+type storage interface {
+	repository.Store
+	repository.AccountStore
+	repository.BlocklistStore
+}
+
+// newStore cria o backend de armazenamento escolhido na inicialização
+func newStore(backend, path string) (storage, error) {
+	switch backend {
+	case "memory":
+		return repository.NewMemoryStore(), nil
+	case "file":
+		log.Printf("💾 Armazenamento em arquivo: %s", path)
+		return repository.NewFileStore(path)
+	default:
+		return nil, fmt.Errorf("backend de armazenamento desconhecido: %q", backend)
+	}
+}
@@ -0,0 +1,413 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+	"url-shortener/internal/service"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config reúne as configurações do servidor. Os valores vêm, em ordem de
+// precedência crescente, dos padrões, do arquivo opcional (YAML ou JSON) e
+// das variáveis de ambiente.
+type Config struct {
+	ListenAddr string `json:"listen_addr" yaml:"listen_addr"`
+	BaseURL    string `json:"base_url" yaml:"base_url"` // URL pública usada nos links curtos
+	// Domínios adicionais (marcas), cada um com seus próprios códigos
+	Domains []string `json:"domains" yaml:"domains"`
+
+	Codes      CodeConfig      `json:"codes" yaml:"codes"`
+	Storage    StorageConfig   `json:"storage" yaml:"storage"`
+	RateLimits RateLimitConfig `json:"rate_limits" yaml:"rate_limits"`
+	TLS        TLSConfig       `json:"tls" yaml:"tls"`
+	Clicks     ClickConfig     `json:"clicks" yaml:"clicks"`
+	LinkCheck  LinkCheckConfig `json:"link_check" yaml:"link_check"`
+
+	// Maior lote aceito por /shorten/batch; com o limite de linhas ativo não
+	// pode passar de rate_limits.batch_rows_burst
+	MaxBatchRows int `json:"max_batch_rows" yaml:"max_batch_rows"`
+
+	AdminToken          string   `json:"admin_token" yaml:"admin_token"`
+	TrustedProxies      []string `json:"trusted_proxies" yaml:"trusted_proxies"`
+	GeoIPFile           string   `json:"geoip_cidr_file" yaml:"geoip_cidr_file"`
+	ResolveDestinations bool     `json:"resolve_destinations" yaml:"resolve_destinations"`
+	ShutdownTimeout     Duration `json:"shutdown_timeout" yaml:"shutdown_timeout"`
+}
+
+type CodeConfig struct {
+	Length   int    `json:"length" yaml:"length"`
+	Alphabet string `json:"alphabet" yaml:"alphabet"`
+	Strategy string `json:"strategy" yaml:"strategy"` // random ou counter
+	Salt     string `json:"salt" yaml:"salt"`         // embaralha os códigos da estratégia counter
+}
+
+type StorageConfig struct {
+	Backend       string   `json:"backend" yaml:"backend"` // memory ou file
+	Path          string   `json:"path" yaml:"path"`
+	SweepInterval Duration `json:"sweep_interval" yaml:"sweep_interval"`
+	// Tempo que um link expirado mantém dados e estatísticas antes de ser
+	// removido; o código continua reservado depois disso
+	ExpiredRetention Duration `json:"expired_retention" yaml:"expired_retention"`
+}
+
+// RateLimitConfig define os limites por minuto e as rajadas; 0 desativa
+type RateLimitConfig struct {
+	CreatePerMin   int `json:"create_per_min" yaml:"create_per_min"`
+	CreateBurst    int `json:"create_burst" yaml:"create_burst"`
+	RedirectPerMin int `json:"redirect_per_min" yaml:"redirect_per_min"`
+	RedirectBurst  int `json:"redirect_burst" yaml:"redirect_burst"`
+	// Lotes têm orçamento próprio, contado em linhas
+	BatchRowsPerMin int `json:"batch_rows_per_min" yaml:"batch_rows_per_min"`
+	BatchRowsBurst  int `json:"batch_rows_burst" yaml:"batch_rows_burst"`
+}
+
+// TLSConfig ativa HTTPS quando os dois arquivos são informados
+type TLSConfig struct {
+	CertFile string `json:"cert_file" yaml:"cert_file"`
+	KeyFile  string `json:"key_file" yaml:"key_file"`
+}
+
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != "" && t.KeyFile != ""
+}
+
+type ClickConfig struct {
+	FlushInterval Duration `json:"flush_interval" yaml:"flush_interval"`
+	BufferSize    int      `json:"buffer_size" yaml:"buffer_size"`
+}
+
+// LinkCheckConfig controla a verificação periódica dos destinos
+type LinkCheckConfig struct {
+	Enabled     bool     `json:"enabled" yaml:"enabled"`
+	Interval    Duration `json:"interval" yaml:"interval"` // entre verificações de um destino saudável
+	Concurrency int      `json:"concurrency" yaml:"concurrency"`
+	Timeout     Duration `json:"timeout" yaml:"timeout"`
+}
+
+// Duration aceita valores como "30s" ou "1m" no arquivo de configuração
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func defaults() *Config {
+	return &Config{
+		ListenAddr: ":8080",
+		Codes: CodeConfig{
+			Length:   6,
+			Alphabet: service.DefaultCodeAlphabet,
+			Strategy: "random",
+		},
+		Storage: StorageConfig{
+			Backend:       "memory",
+			Path:          "./data/urls.log",
+			SweepInterval: Duration{time.Minute},
+
+			ExpiredRetention: Duration{30 * 24 * time.Hour},
+		},
+		RateLimits: RateLimitConfig{
+			CreatePerMin:   30,
+			CreateBurst:    10,
+			RedirectPerMin: 600,
+			RedirectBurst:  100,
+
+			BatchRowsPerMin: 300,
+			BatchRowsBurst:  1000,
+		},
+		Clicks: ClickConfig{
+			FlushInterval: Duration{time.Second},
+			BufferSize:    10000,
+		},
+		LinkCheck: LinkCheckConfig{
+			Enabled:     false,
+			Interval:    Duration{6 * time.Hour},
+			Concurrency: 8,
+			Timeout:     Duration{10 * time.Second},
+		},
+		MaxBatchRows:        1000,
+		ResolveDestinations: true,
+		ShutdownTimeout:     Duration{15 * time.Second},
+	}
+}
+
+// Load lê o arquivo de configuração (quando path não é vazio), aplica as
+// variáveis de ambiente e valida o resultado
+func Load(path string) (*Config, error) {
+	cfg := defaults()
+
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, fmt.Errorf("arquivo de configuração %s: %w", path, err)
+		}
+	}
+
+	if err := cfg.loadEnv(); err != nil {
+		return nil, err
+	}
+
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = defaultBaseURL(cfg.ListenAddr, cfg.TLS.Enabled())
+	}
+	cfg.BaseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+	for i, domain := range cfg.Domains {
+		cfg.Domains[i] = strings.TrimSuffix(domain, "/")
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		return dec.Decode(c)
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		return dec.Decode(c)
+	default:
+		return fmt.Errorf("formato não suportado: use .yaml, .yml ou .json")
+	}
+}
+
+// loadEnv sobrescreve os valores com as variáveis de ambiente definidas
+func (c *Config) loadEnv() error {
+	e := &envReader{}
+
+	e.str("LISTEN_ADDR", &c.ListenAddr)
+	if port := os.Getenv("PORT"); port != "" && os.Getenv("LISTEN_ADDR") == "" {
+		c.ListenAddr = ":" + port
+	}
+	e.str("BASE_URL", &c.BaseURL)
+	e.list("DOMAINS", &c.Domains)
+
+	e.int("CODE_LENGTH", &c.Codes.Length)
+	e.str("CODE_ALPHABET", &c.Codes.Alphabet)
+	e.str("CODE_STRATEGY", &c.Codes.Strategy)
+	e.str("CODE_SALT", &c.Codes.Salt)
+
+	e.str("STORAGE_BACKEND", &c.Storage.Backend)
+	e.str("STORAGE_PATH", &c.Storage.Path)
+	e.duration("SWEEP_INTERVAL", &c.Storage.SweepInterval)
+	e.duration("EXPIRED_RETENTION", &c.Storage.ExpiredRetention)
+
+	e.int("RATE_LIMIT_CREATE_PER_MIN", &c.RateLimits.CreatePerMin)
+	e.int("RATE_LIMIT_CREATE_BURST", &c.RateLimits.CreateBurst)
+	e.int("RATE_LIMIT_REDIRECT_PER_MIN", &c.RateLimits.RedirectPerMin)
+	e.int("RATE_LIMIT_REDIRECT_BURST", &c.RateLimits.RedirectBurst)
+	e.int("RATE_LIMIT_BATCH_ROWS_PER_MIN", &c.RateLimits.BatchRowsPerMin)
+	e.int("RATE_LIMIT_BATCH_ROWS_BURST", &c.RateLimits.BatchRowsBurst)
+	e.int("MAX_BATCH_ROWS", &c.MaxBatchRows)
+
+	e.str("TLS_CERT_FILE", &c.TLS.CertFile)
+	e.str("TLS_KEY_FILE", &c.TLS.KeyFile)
+
+	e.duration("CLICK_FLUSH_INTERVAL", &c.Clicks.FlushInterval)
+	e.int("CLICK_BUFFER_SIZE", &c.Clicks.BufferSize)
+
+	e.bool("LINK_CHECK_ENABLED", &c.LinkCheck.Enabled)
+	e.duration("LINK_CHECK_INTERVAL", &c.LinkCheck.Interval)
+	e.int("LINK_CHECK_CONCURRENCY", &c.LinkCheck.Concurrency)
+	e.duration("LINK_CHECK_TIMEOUT", &c.LinkCheck.Timeout)
+
+	e.str("ADMIN_TOKEN", &c.AdminToken)
+	e.list("TRUSTED_PROXIES", &c.TrustedProxies)
+	e.str("GEOIP_CIDR_FILE", &c.GeoIPFile)
+	e.bool("RESOLVE_DESTINATIONS", &c.ResolveDestinations)
+	e.duration("SHUTDOWN_TIMEOUT", &c.ShutdownTimeout)
+
+	return e.err
+}
+
+// Validate verifica a consistência da configuração
+func (c *Config) Validate() error {
+	if _, _, err := net.SplitHostPort(c.ListenAddr); err != nil {
+		return fmt.Errorf("listen_addr inválido %q: use host:porta ou :porta", c.ListenAddr)
+	}
+
+	u, err := url.Parse(c.BaseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+		return fmt.Errorf("base_url inválida %q: use uma URL http(s) sem query", c.BaseURL)
+	}
+
+	// Os links dos domínios adicionais são resolvidos pelo Host, então a URL
+	// base não pode ter caminho
+	for _, domain := range c.Domains {
+		u, err := url.Parse(domain)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" || u.RawQuery != "" || u.Fragment != "" {
+			return fmt.Errorf("domínio inválido %q em domains: use uma URL como https://marca.link", domain)
+		}
+	}
+
+	if c.Codes.Length < 4 || c.Codes.Length > 16 {
+		return fmt.Errorf("codes.length deve estar entre 4 e 16")
+	}
+	if err := validateAlphabet(c.Codes.Alphabet); err != nil {
+		return err
+	}
+	if c.Codes.Strategy != "random" && c.Codes.Strategy != "counter" {
+		return fmt.Errorf("codes.strategy desconhecida: %q (use random ou counter)", c.Codes.Strategy)
+	}
+
+	switch c.Storage.Backend {
+	case "memory":
+	case "file":
+		if c.Storage.Path == "" {
+			return fmt.Errorf("storage.path é obrigatório com o backend file")
+		}
+	default:
+		return fmt.Errorf("storage.backend desconhecido: %q", c.Storage.Backend)
+	}
+
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		return fmt.Errorf("tls.cert_file e tls.key_file devem ser informados juntos")
+	}
+
+	if c.Storage.SweepInterval.Duration <= 0 || c.Clicks.FlushInterval.Duration <= 0 || c.ShutdownTimeout.Duration <= 0 {
+		return fmt.Errorf("sweep_interval, flush_interval e shutdown_timeout devem ser positivos")
+	}
+	if c.Storage.ExpiredRetention.Duration < 0 {
+		return fmt.Errorf("storage.expired_retention não pode ser negativo")
+	}
+	if c.MaxBatchRows < 1 {
+		return fmt.Errorf("max_batch_rows deve ser positivo")
+	}
+	// Um lote maior que a rajada nunca teria tokens suficientes
+	if c.RateLimits.BatchRowsPerMin > 0 && c.MaxBatchRows > c.RateLimits.BatchRowsBurst {
+		return fmt.Errorf("max_batch_rows (%d) não pode passar de rate_limits.batch_rows_burst (%d)", c.MaxBatchRows, c.RateLimits.BatchRowsBurst)
+	}
+	if c.LinkCheck.Enabled && (c.LinkCheck.Interval.Duration <= 0 || c.LinkCheck.Timeout.Duration <= 0 || c.LinkCheck.Concurrency < 1) {
+		return fmt.Errorf("link_check.interval, link_check.timeout e link_check.concurrency devem ser positivos")
+	}
+	return nil
+}
+
+// validateAlphabet exige ao menos 16 caracteres ASCII distintos que possam
+// aparecer no caminho da URL sem codificação
+func validateAlphabet(alphabet string) error {
+	if len(alphabet) < 16 || !utf8.ValidString(alphabet) {
+		return fmt.Errorf("codes.alphabet deve ter pelo menos 16 caracteres")
+	}
+
+	seen := make(map[rune]bool)
+	for _, c := range alphabet {
+		isAlnum := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+		if !isAlnum && c != '-' && c != '_' {
+			return fmt.Errorf("codes.alphabet aceita apenas letras, números, '-' e '_'")
+		}
+		if seen[c] {
+			return fmt.Errorf("codes.alphabet tem o caractere %q repetido", c)
+		}
+		seen[c] = true
+	}
+	return nil
+}
+
+func defaultBaseURL(listenAddr string, tls bool) string {
+	host, port, err := net.SplitHostPort(listenAddr)
+	if err != nil {
+		return "http://localhost:8080"
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+
+	scheme := "http"
+	if tls {
+		scheme = "https"
+	}
+	return scheme + "://" + net.JoinHostPort(host, port)
+}
+
+// envReader lê variáveis de ambiente guardando o primeiro erro de conversão
+type envReader struct {
+	err error
+}
+
+func (e *envReader) lookup(key string) (string, bool) {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" || e.err != nil {
+		return "", false
+	}
+	return value, true
+}
+
+func (e *envReader) str(key string, dst *string) {
+	if value, ok := e.lookup(key); ok {
+		*dst = value
+	}
+}
+
+func (e *envReader) int(key string, dst *int) {
+	if value, ok := e.lookup(key); ok {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			e.err = fmt.Errorf("%s: número inválido %q", key, value)
+			return
+		}
+		*dst = n
+	}
+}
+
+func (e *envReader) bool(key string, dst *bool) {
+	if value, ok := e.lookup(key); ok {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			e.err = fmt.Errorf("%s: use true ou false", key)
+			return
+		}
+		*dst = b
+	}
+}
+
+func (e *envReader) duration(key string, dst *Duration) {
+	if value, ok := e.lookup(key); ok {
+		if err := dst.UnmarshalText([]byte(value)); err != nil {
+			e.err = fmt.Errorf("%s: duração inválida %q", key, value)
+		}
+	}
+}
+
+// list lê valores separados por vírgula
+func (e *envReader) list(key string, dst *[]string) {
+	if value, ok := e.lookup(key); ok {
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		*dst = items
+	}
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"url-shortener/internal/repository"
+	"url-shortener/internal/service"
+)
+
+type AdminHandler struct {
+	blocklist *service.BlocklistService
+	urls      *service.URLService
+}
+
+func NewAdminHandler(blocklist *service.BlocklistService, urls *service.URLService) *AdminHandler {
+	return &AdminHandler{blocklist: blocklist, urls: urls}
+}
+
+// Ocupação do espaço de códigos: GET /admin/keyspace
+func (h *AdminHandler) Keyspace(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.urls.KeyspaceUsage())
+}
+
+// Lista de domínios bloqueados: GET, POST e DELETE /admin/blocklist
+func (h *AdminHandler) Blocklist(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(h.blocklist.List())
+
+	case http.MethodPost:
+		var req struct {
+			Pattern string `json:"pattern"`
+			Reason  string `json:"reason"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "JSON inválido", http.StatusBadRequest)
+			return
+		}
+
+		domain, err := h.blocklist.Block(req.Pattern, req.Reason)
+		if errors.Is(err, service.ErrInvalidPattern) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(domain)
+
+	case http.MethodDelete:
+		// DELETE /admin/blocklist?pattern=*.evil.com
+		err := h.blocklist.Unblock(r.URL.Query().Get("pattern"))
+		if errors.Is(err, repository.ErrPatternNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+	}
+}
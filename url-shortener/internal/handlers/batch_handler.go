@@ -0,0 +1,390 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"url-shortener/internal/middleware"
+	"url-shortener/internal/models"
+)
+
+const (
+	defaultMaxBatchRows = 1000
+	maxBatchBodySize    = 10 << 20 // 10 MB
+
+	// Primeiros caracteres que fazem uma planilha tratar a célula como fórmula
+	csvFormulaChars = "=+-@\t\r"
+)
+
+// errTooManyRows informa o maior lote aceito
+type errTooManyRows int
+
+func (e errTooManyRows) Error() string {
+	return fmt.Sprintf("o lote pode ter no máximo %d linhas", int(e))
+}
+
+// Colunas aceitas no CSV de entrada; os nomes do export também são aceitos
+// para permitir reimportar uma planilha exportada
+var csvColumns = map[string]string{
+	"url":           "url",
+	"original_url":  "url",
+	"alias":         "alias",
+	"short_code":    "alias",
+	"code_length":   "code_length",
+	"expires_at":    "expires_at",
+	"max_clicks":    "max_clicks",
+	"domain":        "domain",
+	"dedupe":        "dedupe",
+	"redirect_type": "redirect_type",
+	"forward_query": "forward_query",
+	"password":      "password",
+	"utm_source":    "utm_source",
+	"utm_medium":    "utm_medium",
+	"utm_campaign":  "utm_campaign",
+	"utm_term":      "utm_term",
+	"utm_content":   "utm_content",
+}
+
+var exportHeader = []string{"short_code", "short_url", "original_url", "created_at", "expires_at", "max_clicks", "clicks", "unique_visitors", "last_click_at", "domain"}
+
+// batchRow é uma linha do lote; Err guarda erros de parsing da própria linha
+type batchRow struct {
+	Request models.CreateURLRequest
+	Err     error
+}
+
+// Criar URLs curtas em lote: POST /shorten/batch com um array JSON,
+// um CSV (text/csv) ou um upload multipart com o campo "file"
+func (h *URLHandler) CreateBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBatchBodySize)
+
+	rows, err := readBatch(r, h.maxBatchRows)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.As(err, new(errTooManyRows)) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	// Cada linha cria um link e custa um token; a primeira já foi cobrada
+	// pela rota
+	if h.batchLimiter != nil && !h.batchLimiter.Take(w, r, len(rows)-1) {
+		return
+	}
+
+	ownerID := middleware.GetAccountID(r)
+	resp := models.BatchResponse{Results: make([]models.BatchResult, 0, len(rows))}
+
+	for i, row := range rows {
+		result := models.BatchResult{Row: i + 1}
+
+		if row.Err != nil {
+			result.Status = http.StatusBadRequest
+			result.Error = row.Err.Error()
+		} else {
+			created, err := h.service.CreateShortURL(row.Request, ownerID)
+			if err != nil {
+				result.Status = createErrorStatus(err)
+				result.Error = err.Error()
+			} else {
+				result.Status = http.StatusCreated
+				if created.Existing {
+					result.Status = http.StatusOK
+				}
+				result.ShortURL = created.ShortURL
+				result.OriginalURL = created.OriginalURL
+			}
+		}
+
+		if result.Error != "" {
+			resp.Failed++
+		} else {
+			resp.Created++
+		}
+		resp.Results = append(resp.Results, result)
+	}
+
+	if strings.Contains(r.Header.Get("Accept"), "text/csv") {
+		writeBatchCSV(w, resp.Results)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Exportar links da conta: GET /links/export?format=csv|ndjson
+func (h *LinkHandler) Export(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ownerID := middleware.GetAccountID(r)
+	filename := "links-" + time.Now().Format("20060102-150405")
+
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`.csv"`)
+
+		cw := csv.NewWriter(w)
+		cw.Write(exportHeader)
+		err := h.service.ExportLinks(ownerID, func(row models.LinkExport) error {
+			cw.Write([]string{
+				csvCell(row.ShortCode),
+				csvCell(row.ShortURL),
+				csvCell(row.OriginalURL),
+				row.CreatedAt.Format(time.RFC3339),
+				formatOptionalTime(row.ExpiresAt),
+				formatOptionalInt(row.MaxClicks),
+				strconv.Itoa(row.Clicks),
+				strconv.Itoa(row.UniqueVisitors),
+				formatOptionalTime(row.LastClickAt),
+				csvCell(row.Domain),
+			})
+			return cw.Error()
+		})
+		cw.Flush()
+		if err != nil {
+			log.Printf("Erro ao exportar links: %v", err)
+		}
+
+	case "ndjson":
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`.ndjson"`)
+
+		bw := bufio.NewWriter(w)
+		enc := json.NewEncoder(bw)
+		err := h.service.ExportLinks(ownerID, func(row models.LinkExport) error {
+			return enc.Encode(row)
+		})
+		bw.Flush()
+		if err != nil {
+			log.Printf("Erro ao exportar links: %v", err)
+		}
+
+	default:
+		http.Error(w, "Formato inválido: use csv ou ndjson", http.StatusBadRequest)
+	}
+}
+
+// readBatch lê até maxRows linhas do lote conforme o Content-Type
+func readBatch(r *http.Request, maxRows int) ([]batchRow, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	switch mediaType {
+	case "text/csv":
+		return readBatchCSV(r.Body, maxRows)
+
+	case "multipart/form-data":
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			return nil, errors.New("arquivo CSV não enviado no campo \"file\"")
+		}
+		defer file.Close()
+		return readBatchCSV(file, maxRows)
+
+	default:
+		var reqs []models.CreateURLRequest
+		if err := json.NewDecoder(r.Body).Decode(&reqs); err != nil {
+			return nil, errors.New("JSON inválido: esperado um array de URLs")
+		}
+		if len(reqs) > maxRows {
+			return nil, errTooManyRows(maxRows)
+		}
+
+		rows := make([]batchRow, len(reqs))
+		for i, req := range reqs {
+			rows[i] = batchRow{Request: req}
+		}
+		return rows, nil
+	}
+}
+
+// readBatchCSV lê um CSV com cabeçalho; colunas desconhecidas são ignoradas
+func readBatchCSV(body io.Reader, maxRows int) ([]batchRow, error) {
+	cr := csv.NewReader(body)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, errors.New("CSV vazio ou inválido")
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if field, ok := csvColumns[name]; ok {
+			if _, seen := columns[field]; !seen {
+				columns[field] = i
+			}
+		}
+	}
+	if _, ok := columns["url"]; !ok {
+		return nil, errors.New("CSV sem a coluna url")
+	}
+
+	var rows []batchRow
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if len(rows) == maxRows {
+			return nil, errTooManyRows(maxRows)
+		}
+		if err != nil {
+			rows = append(rows, batchRow{Err: fmt.Errorf("linha CSV inválida: %w", err)})
+			continue
+		}
+
+		rows = append(rows, parseCSVRecord(record, columns))
+	}
+	return rows, nil
+}
+
+func parseCSVRecord(record []string, columns map[string]int) batchRow {
+	get := func(field string) string {
+		if i, ok := columns[field]; ok && i < len(record) {
+			return strings.TrimSpace(uncsvCell(record[i]))
+		}
+		return ""
+	}
+
+	row := batchRow{Request: models.CreateURLRequest{
+		URL:      get("url"),
+		Alias:    get("alias"),
+		Domain:   get("domain"),
+		Password: get("password"),
+	}}
+
+	if value := get("code_length"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			row.Err = errors.New("code_length inválido")
+			return row
+		}
+		row.Request.CodeLength = n
+	}
+
+	if value := get("expires_at"); value != "" {
+		t, err := parseTimeParam(value)
+		if err != nil {
+			row.Err = errors.New("expires_at inválido")
+			return row
+		}
+		row.Request.ExpiresAt = &t
+	}
+
+	if value := get("max_clicks"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			row.Err = errors.New("max_clicks inválido")
+			return row
+		}
+		row.Request.MaxClicks = n
+	}
+
+	if value := get("redirect_type"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			row.Err = errors.New("redirect_type inválido")
+			return row
+		}
+		row.Request.RedirectType = n
+	}
+
+	if value := get("dedupe"); value != "" {
+		dedupe, err := strconv.ParseBool(value)
+		if err != nil {
+			row.Err = errors.New("dedupe inválido")
+			return row
+		}
+		row.Request.Dedupe = dedupe
+	}
+
+	if value := get("forward_query"); value != "" {
+		forward, err := strconv.ParseBool(value)
+		if err != nil {
+			row.Err = errors.New("forward_query inválido")
+			return row
+		}
+		row.Request.ForwardQuery = forward
+	}
+
+	row.Request.UTM = &models.UTMParams{
+		Source:   get("utm_source"),
+		Medium:   get("utm_medium"),
+		Campaign: get("utm_campaign"),
+		Term:     get("utm_term"),
+		Content:  get("utm_content"),
+	}
+
+	return row
+}
+
+func writeBatchCSV(w http.ResponseWriter, results []models.BatchResult) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"row", "status", "short_url", "original_url", "error"})
+	for _, result := range results {
+		cw.Write([]string{
+			strconv.Itoa(result.Row),
+			strconv.Itoa(result.Status),
+			csvCell(result.ShortURL),
+			csvCell(result.OriginalURL),
+			csvCell(result.Error),
+		})
+	}
+	cw.Flush()
+}
+
+// csvCell evita que planilhas interpretem o valor como fórmula: células que
+// começam com =, +, -, @, tab ou CR ganham um apóstrofo na frente
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune(csvFormulaChars, rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// uncsvCell desfaz o apóstrofo de csvCell para que o export seja reimportado
+func uncsvCell(value string) string {
+	if len(value) > 1 && value[0] == '\'' && strings.ContainsRune(csvFormulaChars, rune(value[1])) {
+		return value[1:]
+	}
+	return value
+}
+
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func formatOptionalInt(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
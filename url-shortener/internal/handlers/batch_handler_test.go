@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"url-shortener/internal/middleware"
+	"url-shortener/internal/models"
+	"url-shortener/internal/repository"
+	"url-shortener/internal/service"
+)
+
+func TestExportCSVEscapesFormulas(t *testing.T) {
+	store := repository.NewMemoryStore()
+	urlService := service.NewURLService(store, "https://sho.rt")
+	urlService.Validator().SetResolver(nil)
+	accounts := service.NewAccountService(store)
+
+	account, err := accounts.CreateAccount("time")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := urlService.CreateShortURL(models.CreateURLRequest{URL: "https://example.com", Alias: "-cmd"}, account.Account.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := middleware.APIKeyAuth(accounts)(http.HandlerFunc(NewLinkHandler(urlService).Export))
+	req := httptest.NewRequest(http.MethodGet, "/links/export?format=csv", nil)
+	req.Header.Set("X-API-Key", account.APIKey)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	records, err := csv.NewReader(strings.NewReader(rec.Body.String())).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("export com %d linhas, esperado cabeçalho e 1 link", len(records))
+	}
+	if records[1][0] != "'-cmd" {
+		t.Errorf("short_code exportado como %q, esperado '-cmd", records[1][0])
+	}
+
+	// O apóstrofo é removido ao reimportar o export
+	rows, err := readBatchCSV(strings.NewReader(rec.Body.String()), defaultMaxBatchRows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows[0].Request.Alias != "-cmd" {
+		t.Errorf("alias reimportado como %q, esperado -cmd", rows[0].Request.Alias)
+	}
+}
+
+func TestCSVCell(t *testing.T) {
+	tests := map[string]string{
+		"=HYPERLINK(\"x\")": "'=HYPERLINK(\"x\")",
+		"+1":                "'+1",
+		"-1":                "'-1",
+		"@SUM(A1)":          "'@SUM(A1)",
+		"\tx":               "'\tx",
+		"https://sho.rt/a":  "https://sho.rt/a",
+		"'texto":            "'texto",
+		"":                  "",
+	}
+	for value, want := range tests {
+		if got := csvCell(value); got != want {
+			t.Errorf("csvCell(%q) = %q, esperado %q", value, got, want)
+		}
+		if got := uncsvCell(csvCell(value)); got != value {
+			t.Errorf("uncsvCell(csvCell(%q)) = %q", value, got)
+		}
+	}
+}
+
+func TestCreateBatchRejectsOversizedBatch(t *testing.T) {
+	urlService := service.NewURLService(repository.NewMemoryStore(), "https://sho.rt")
+	urlService.Validator().SetResolver(nil)
+	handler := NewURLHandler(urlService)
+	handler.SetMaxBatchRows(2)
+
+	post := func(contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/shorten/batch", strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		handler.CreateBatch(rec, req)
+		return rec
+	}
+
+	if rec := post("application/json", `[{"url":"https://a.example.com"},{"url":"https://b.example.com"},{"url":"https://c.example.com"}]`); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("lote JSON acima do limite: status %d, esperado 413", rec.Code)
+	}
+	if rec := post("text/csv", "url\nhttps://a.example.com\nhttps://b.example.com\nhttps://c.example.com\n"); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("lote CSV acima do limite: status %d, esperado 413", rec.Code)
+	}
+	if rec := post("text/csv", "url\nhttps://a.example.com\nhttps://b.example.com\n"); rec.Code != http.StatusOK {
+		t.Errorf("lote no limite: status %d: %s", rec.Code, rec.Body)
+	}
+}
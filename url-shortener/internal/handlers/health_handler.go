@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"url-shortener/internal/repository"
+)
+
+type HealthHandler struct {
+	store        any // verificado se implementar repository.HealthChecker
+	shuttingDown atomic.Bool
+}
+
+func NewHealthHandler(store any) *HealthHandler {
+	return &HealthHandler{store: store}
+}
+
+type healthResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// Liveness: GET /healthz responde enquanto o processo estiver de pé
+func (h *HealthHandler) Healthz(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, http.StatusOK, healthResponse{Status: "ok"})
+}
+
+// Readiness: GET /readyz verifica o backend de armazenamento
+func (h *HealthHandler) Readyz(w http.ResponseWriter, r *http.Request) {
+	resp := healthResponse{Status: "ok", Checks: map[string]string{"store": "ok"}}
+	status := http.StatusOK
+
+	if h.shuttingDown.Load() {
+		resp.Status = "shutting_down"
+		status = http.StatusServiceUnavailable
+	}
+
+	if checker, ok := h.store.(repository.HealthChecker); ok {
+		if err := checker.Ping(); err != nil {
+			resp.Status = "unavailable"
+			resp.Checks["store"] = err.Error()
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	writeHealth(w, status, resp)
+}
+
+// SetShuttingDown faz o /readyz falhar para o balanceador parar de enviar
+// tráfego enquanto o servidor encerra
+func (h *HealthHandler) SetShuttingDown() {
+	h.shuttingDown.Store(true)
+}
+
+func writeHealth(w http.ResponseWriter, status int, resp healthResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"url-shortener/internal/middleware"
+	"url-shortener/internal/models"
+	"url-shortener/internal/repository"
+	"url-shortener/internal/service"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 500
+)
+
+// LinkHandler gerencia os links da conta autenticada
+type LinkHandler struct {
+	service *service.URLService
+}
+
+func NewLinkHandler(service *service.URLService) *LinkHandler {
+	return &LinkHandler{service: service}
+}
+
+// Listar links da conta: GET /links?limit=&offset=
+func (h *LinkHandler) ListLinks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit, offset, ok := pageParams(w, r)
+	if !ok {
+		return
+	}
+
+	resp := h.service.ListLinks(middleware.GetAccountID(r), limit, offset)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Links da conta com destino quebrado: GET /links/broken?limit=&offset=
+func (h *LinkHandler) BrokenLinks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit, offset, ok := pageParams(w, r)
+	if !ok {
+		return
+	}
+
+	resp := h.service.BrokenLinks(middleware.GetAccountID(r), limit, offset)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Alterar ou remover um link da conta: PATCH/DELETE /links/{code}
+func (h *LinkHandler) Link(w http.ResponseWriter, r *http.Request) {
+	shortCode := strings.TrimPrefix(r.URL.Path, "/links/")
+	if shortCode == "" || strings.Contains(shortCode, "/") {
+		http.Error(w, "Código não fornecido", http.StatusBadRequest)
+		return
+	}
+
+	shortCode = h.service.LinkKey(linkHost(r), shortCode)
+
+	switch r.Method {
+	case http.MethodPatch:
+		h.updateLink(w, r, shortCode)
+	case http.MethodDelete:
+		h.deleteLink(w, r, shortCode)
+	default:
+		http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *LinkHandler) updateLink(w http.ResponseWriter, r *http.Request, shortCode string) {
+	var req models.UpdateURLRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "JSON inválido", http.StatusBadRequest)
+		return
+	}
+
+	url, err := h.service.UpdateLink(middleware.GetAccountID(r), shortCode, req)
+	if err != nil {
+		http.Error(w, err.Error(), linkErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(url)
+}
+
+func (h *LinkHandler) deleteLink(w http.ResponseWriter, r *http.Request, shortCode string) {
+	if err := h.service.DeleteLink(middleware.GetAccountID(r), shortCode); err != nil {
+		http.Error(w, err.Error(), linkErrorStatus(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// linkErrorStatus mapeia erros de gerenciamento de links para o status HTTP
+func linkErrorStatus(err error) int {
+	if errors.Is(err, repository.ErrNotFound) {
+		return http.StatusNotFound
+	}
+	if errors.Is(err, service.ErrURLExpired) {
+		return http.StatusGone
+	}
+	return createErrorStatus(err)
+}
+
+// pageParams lê ?limit= e ?offset= das listagens; responde 400 e retorna
+// false se forem inválidos
+func pageParams(w http.ResponseWriter, r *http.Request) (limit, offset int, ok bool) {
+	limit, err := intParam(r, "limit", defaultListLimit)
+	if err != nil || limit < 1 || limit > maxListLimit {
+		http.Error(w, "Parâmetro limit inválido", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	offset, err = intParam(r, "offset", 0)
+	if err != nil || offset < 0 {
+		http.Error(w, "Parâmetro offset inválido", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	return limit, offset, true
+}
+
+func intParam(r *http.Request, name string, defaultValue int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	return strconv.Atoi(value)
+}
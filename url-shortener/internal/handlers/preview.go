@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+	"net/url"
+	"url-shortener/internal/models"
+)
+
+// Página intermediária de GET /{code}+: mostra o destino antes de seguir
+var previewTemplate = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html lang="pt-BR">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Pré-visualização de {{.ShortURL}}</title>
+{{with .OpenGraph}}{{template "og" .}}{{end}}
+<style>
+body { font-family: system-ui, sans-serif; max-width: 40rem; margin: 4rem auto; padding: 0 1rem; color: #222; }
+.destination { word-break: break-all; padding: 1rem; background: #f4f4f4; border-radius: .5rem; }
+.host { font-size: 1.25rem; font-weight: bold; }
+a.button { display: inline-block; margin-top: 1.5rem; padding: .75rem 1.5rem; background: #2563eb; color: #fff; border-radius: .5rem; text-decoration: none; }
+</style>
+</head>
+<body>
+<h1>Você está saindo do encurtador</h1>
+<p><strong>{{.ShortURL}}</strong> redireciona para:</p>
+<div class="destination">
+<div class="host">{{.Host}}</div>
+<div>{{.Destination}}</div>
+</div>
+{{with .OpenGraph}}{{if .Title}}<h2>{{.Title}}</h2>{{end}}{{if .Description}}<p>{{.Description}}</p>{{end}}{{end}}
+<a class="button" href="{{.ShortURL}}" rel="noopener noreferrer">Continuar para o destino</a>
+</body>
+</html>
+`))
+
+// Página servida aos crawlers de redes sociais com os metadados do link
+var openGraphTemplate = template.Must(template.New("opengraph").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.OpenGraph.Title}}</title>
+<meta property="og:url" content="{{.ShortURL}}">
+{{template "og" .OpenGraph}}
+<meta http-equiv="refresh" content="0; url={{.Destination}}">
+</head>
+<body><a href="{{.Destination}}">{{.Destination}}</a></body>
+</html>
+`))
+
+// Formulário de senha dos links protegidos; envia POST para a própria URL
+// curta, preservando a query
+var unlockTemplate = template.Must(template.New("unlock").Parse(`<!DOCTYPE html>
+<html lang="pt-BR">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Link protegido</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 24rem; margin: 4rem auto; padding: 0 1rem; color: #222; }
+input { width: 100%; box-sizing: border-box; padding: .75rem; margin: .5rem 0 1rem; border: 1px solid #ccc; border-radius: .5rem; }
+button { padding: .75rem 1.5rem; background: #2563eb; color: #fff; border: 0; border-radius: .5rem; cursor: pointer; }
+.error { color: #b91c1c; }
+</style>
+</head>
+<body>
+<h1>Link protegido</h1>
+<p>Digite a senha para continuar.</p>
+{{with .Error}}<p class="error">{{.}}</p>{{end}}
+<form method="post">
+<label for="password">Senha</label>
+<input id="password" name="password" type="password" autocomplete="current-password" autofocus required>
+<button type="submit">Continuar</button>
+</form>
+</body>
+</html>
+`))
+
+const openGraphTags = `{{define "og"}}<meta property="og:type" content="website">
+{{if .Title}}<meta property="og:title" content="{{.Title}}">
+<meta name="twitter:title" content="{{.Title}}">{{end}}
+{{if .Description}}<meta property="og:description" content="{{.Description}}">
+<meta name="twitter:description" content="{{.Description}}">{{end}}
+{{if .Image}}<meta property="og:image" content="{{.Image}}">
+<meta name="twitter:card" content="summary_large_image">
+<meta name="twitter:image" content="{{.Image}}">{{else}}<meta name="twitter:card" content="summary">{{end}}{{end}}`
+
+func init() {
+	template.Must(previewTemplate.Parse(openGraphTags))
+	template.Must(openGraphTemplate.Parse(openGraphTags))
+}
+
+type previewPage struct {
+	ShortURL    string
+	Destination string
+	Host        string
+	OpenGraph   *models.OpenGraph
+}
+
+type unlockPage struct {
+	Error string
+}
+
+func renderUnlock(w http.ResponseWriter, status int, page unlockPage) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	unlockTemplate.Execute(w, page)
+}
+
+func renderPage(w http.ResponseWriter, tmpl *template.Template, page previewPage) {
+	if u, err := url.Parse(page.Destination); err == nil {
+		page.Host = u.Hostname()
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	if err := tmpl.Execute(w, page); err != nil {
+		http.Error(w, "Erro ao renderizar página", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"url-shortener/internal/service"
+	"url-shortener/pkg/qr"
+)
+
+// QR code da URL curta: GET /qr/{code}?format=png|svg&size=256&level=M&margin=4
+func (h *URLHandler) GetQRCode(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	shortCode := strings.TrimPrefix(r.URL.Path, "/qr/")
+	if shortCode == "" {
+		http.Error(w, "Código não fornecido", http.StatusBadRequest)
+		return
+	}
+
+	opts, format, err := qrOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	shortURL, err := h.service.ShortURL(h.service.LinkKey(linkHost(r), shortCode))
+	if errors.Is(err, service.ErrURLExpired) {
+		http.Error(w, "URL expirada", http.StatusGone)
+		return
+	}
+	if err != nil {
+		http.Error(w, "URL não encontrada", http.StatusNotFound)
+		return
+	}
+
+	var image []byte
+	contentType := "image/png"
+	if format == "svg" {
+		image, err = qr.SVG(shortURL, opts)
+		contentType = "image/svg+xml"
+	} else {
+		image, err = qr.PNG(shortURL, opts)
+	}
+	if errors.Is(err, qr.ErrInvalidSize) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Erro ao gerar QR code", http.StatusInternalServerError)
+		return
+	}
+
+	// O link pode expirar ou ser removido, então o cliente revalida a cada
+	// uso; o ETag evita reenviar a imagem enquanto ela não muda
+	sum := sha256.Sum256(image)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(image))
+}
+
+func qrOptions(r *http.Request) (qr.Options, string, error) {
+	query := r.URL.Query()
+	opts := qr.DefaultOptions()
+
+	format := strings.ToLower(query.Get("format"))
+	if format == "" {
+		format = "png"
+	}
+	if format != "png" && format != "svg" {
+		return opts, "", errors.New("format deve ser png ou svg")
+	}
+
+	if v := query.Get("size"); v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil {
+			return opts, "", qr.ErrInvalidSize
+		}
+		opts.Size = size
+	}
+	if v := query.Get("level"); v != "" {
+		opts.Level = strings.ToUpper(v)
+	}
+	if v := query.Get("margin"); v != "" {
+		margin, err := strconv.Atoi(v)
+		if err != nil {
+			return opts, "", qr.ErrInvalidMargin
+		}
+		opts.Margin = margin
+	}
+
+	return opts, format, opts.Validate()
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"url-shortener/internal/models"
+	"url-shortener/internal/repository"
+	"url-shortener/internal/service"
+)
+
+func TestQRCodeRevalidatesWithETag(t *testing.T) {
+	store := repository.NewMemoryStore()
+	urlService := service.NewURLService(store, "https://sho.rt")
+	urlService.Validator().SetResolver(nil)
+	if _, err := urlService.CreateShortURL(models.CreateURLRequest{URL: "https://example.com", Alias: "promo"}, ""); err != nil {
+		t.Fatal(err)
+	}
+	handler := NewURLHandler(urlService)
+
+	get := func(etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/qr/promo", nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		rec := httptest.NewRecorder()
+		handler.GetQRCode(rec, req)
+		return rec
+	}
+
+	rec := get("")
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || rec.Header().Get("Cache-Control") != "no-cache" || etag == "" {
+		t.Fatalf("status %d, Cache-Control %q, ETag %q", rec.Code, rec.Header().Get("Cache-Control"), etag)
+	}
+	if rec := get(etag); rec.Code != http.StatusNotModified {
+		t.Errorf("revalidação com o mesmo ETag: status %d, esperado 304", rec.Code)
+	}
+
+	// Removido o link, a revalidação não devolve a imagem antiga
+	if err := store.Delete("promo"); err != nil {
+		t.Fatal(err)
+	}
+	if rec := get(etag); rec.Code != http.StatusNotFound {
+		t.Errorf("QR de link removido: status %d, esperado 404", rec.Code)
+	}
+}
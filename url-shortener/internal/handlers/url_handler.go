@@ -2,18 +2,40 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
+	"url-shortener/internal/metrics"
+	"url-shortener/internal/middleware"
 	"url-shortener/internal/models"
 	"url-shortener/internal/service"
 )
 
+// Limite do corpo do formulário de senha
+const maxUnlockBodySize = 4 << 10
+
 type URLHandler struct {
-	service *service.URLService
+	service      *service.URLService
+	batchLimiter *middleware.RateLimiter // cobra as linhas dos lotes
+	maxBatchRows int
 }
 
 func NewURLHandler(service *service.URLService) *URLHandler {
-	return &URLHandler{service: service}
+	return &URLHandler{service: service, maxBatchRows: defaultMaxBatchRows}
+}
+
+// SetMaxBatchRows define o maior lote aceito por POST /shorten/batch
+func (h *URLHandler) SetMaxBatchRows(n int) {
+	h.maxBatchRows = n
+}
+
+// SetBatchLimiter cobra cada linha de POST /shorten/batch do limitador; a
+// rota deve usar o mesmo limitador, que cobra a primeira linha antes de o
+// corpo ser lido
+func (h *URLHandler) SetBatchLimiter(limiter *middleware.RateLimiter) {
+	h.batchLimiter = limiter
 }
 
 // Criar URL curta
@@ -29,30 +51,25 @@ func (h *URLHandler) CreateURL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	if req.URL == "" {
-		http.Error(w, "URL é obrigatória", http.StatusBadRequest)
+	resp, err := h.service.CreateShortURL(req, middleware.GetAccountID(r))
+	if err != nil {
+		http.Error(w, err.Error(), createErrorStatus(err))
 		return
 	}
 	
-	// Adicionar http:// se não tiver protocolo
-	if !strings.HasPrefix(req.URL, "http://") && !strings.HasPrefix(req.URL, "https://") {
-		req.URL = "https://" + req.URL
-	}
-	
-	resp, err := h.service.CreateShortURL(req.URL)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	status := http.StatusCreated
+	if resp.Existing {
+		status = http.StatusOK
 	}
 	
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(resp)
 }
 
-// Redirecionar para URL original
+// Redirecionar para URL original; GET /{code}+ mostra a pré-visualização
 func (h *URLHandler) RedirectURL(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
 		http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
 		return
 	}
@@ -63,14 +80,119 @@ func (h *URLHandler) RedirectURL(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Código não fornecido", http.StatusBadRequest)
 		return
 	}
+
+	if code, ok := strings.CutSuffix(shortCode, "+"); ok {
+		if r.Method == http.MethodGet {
+			h.preview(w, r, h.service.LinkKey(r.Host, code))
+			return
+		}
+		// Senha enviada pelo formulário mostrado na pré-visualização
+		shortCode = code
+	}
+
+	// O mesmo código pode existir em vários domínios; o Host decide qual
+	key := h.service.LinkKey(r.Host, shortCode)
+
+	// POST é o envio do formulário de senha
+	if r.Method == http.MethodPost {
+		h.unlock(w, r, key)
+		return
+	}
 	
-	originalURL, err := h.service.GetOriginalURL(shortCode)
+	redirect, err := h.service.GetOriginalURL(key, requestInfo(r))
+	if errors.Is(err, service.ErrPasswordRequired) {
+		renderUnlock(w, http.StatusOK, unlockPage{})
+		return
+	}
 	if err != nil {
-		http.Error(w, "URL não encontrada", http.StatusNotFound)
+		http.Error(w, redirectErrorMessage(err), redirectErrorStatus(err))
 		return
 	}
-	
-	http.Redirect(w, r, originalURL, http.StatusMovedPermanently)
+
+	if redirect.OpenGraph != nil {
+		renderPage(w, openGraphTemplate, previewPage{
+			ShortURL:    redirect.ShortURL,
+			Destination: redirect.Destination,
+			OpenGraph:   redirect.OpenGraph,
+		})
+		return
+	}
+
+	// Redirecionamentos temporários não devem ficar em cache, senão os
+	// próximos cliques não passam pelo encurtador
+	if redirect.Status == http.StatusFound || redirect.Status == http.StatusTemporaryRedirect {
+		w.Header().Set("Cache-Control", "private, no-cache")
+	}
+	http.Redirect(w, r, redirect.Destination, redirect.Status)
+	metrics.Redirects.Inc(strconv.Itoa(redirect.Status))
+}
+
+// unlock confere a senha enviada pelo formulário e redireciona com 303
+func (h *URLHandler) unlock(w http.ResponseWriter, r *http.Request, key string) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUnlockBodySize)
+
+	redirect, err := h.service.UnlockURL(key, r.PostFormValue("password"), requestInfo(r))
+	switch {
+	case errors.Is(err, service.ErrWrongPassword):
+		renderUnlock(w, http.StatusUnauthorized, unlockPage{Error: "Senha incorreta."})
+		return
+	case errors.Is(err, service.ErrLinkLocked):
+		renderUnlock(w, http.StatusTooManyRequests, unlockPage{Error: "Muitas tentativas incorretas. Tente novamente mais tarde."})
+		return
+	case errors.Is(err, service.ErrNotProtected):
+		// Sem senha o link só aceita GET; o POST não conta clique
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+		return
+	case err != nil:
+		http.Error(w, redirectErrorMessage(err), redirectErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, redirect.Destination, redirect.Status)
+	metrics.Redirects.Inc(strconv.Itoa(redirect.Status))
+}
+
+func (h *URLHandler) preview(w http.ResponseWriter, r *http.Request, key string) {
+	redirect, err := h.service.GetPreview(key, requestInfo(r))
+	if errors.Is(err, service.ErrPasswordRequired) {
+		// A pré-visualização revelaria o destino
+		renderUnlock(w, http.StatusOK, unlockPage{})
+		return
+	}
+	if err != nil {
+		http.Error(w, redirectErrorMessage(err), redirectErrorStatus(err))
+		return
+	}
+
+	renderPage(w, previewTemplate, previewPage{
+		ShortURL:    redirect.ShortURL,
+		Destination: redirect.Destination,
+		OpenGraph:   redirect.OpenGraph,
+	})
+}
+
+func redirectErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrURLExpired):
+		return http.StatusGone
+	case errors.Is(err, service.ErrBlockedDomain):
+		return http.StatusForbidden
+	default:
+		return http.StatusNotFound
+	}
+}
+
+func redirectErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, service.ErrURLExpired):
+		return "URL expirada"
+	case errors.Is(err, service.ErrBlockedDomain):
+		return "Destino bloqueado"
+	default:
+		return "URL não encontrada"
+	}
 }
 
 // Estatísticas da URL
@@ -87,12 +209,99 @@ func (h *URLHandler) GetStats(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	url, err := h.service.GetURLStats(parts[0])
+	// Intervalo opcional: ?from=...&to=... (RFC 3339 ou AAAA-MM-DD)
+	from, err := parseTimeParam(r.URL.Query().Get("from"))
+	if err != nil {
+		http.Error(w, "Parâmetro from inválido", http.StatusBadRequest)
+		return
+	}
+	to, err := parseTimeParam(r.URL.Query().Get("to"))
+	if err != nil {
+		http.Error(w, "Parâmetro to inválido", http.StatusBadRequest)
+		return
+	}
+
+	stats, err := h.service.GetURLStats(h.service.LinkKey(linkHost(r), parts[0]), middleware.GetAccountID(r), from, to)
+	if errors.Is(err, service.ErrInvalidRange) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, service.ErrStatsForbidden) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, service.ErrURLExpired) {
+		http.Error(w, "URL expirada", http.StatusGone)
+		return
+	}
 	if err != nil {
 		http.Error(w, "URL não encontrada", http.StatusNotFound)
 		return
 	}
-	
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(url)
+	json.NewEncoder(w).Encode(stats)
+}
+
+// linkHost retorna o domínio do link pedido na API: ?domain=brand.link ou,
+// sem o parâmetro, o Host da requisição
+func linkHost(r *http.Request) string {
+	if domain := r.URL.Query().Get("domain"); domain != "" {
+		return domain
+	}
+	return r.Host
+}
+
+// requestInfo extrai da requisição os dados usados nas estatísticas de clique
+func requestInfo(r *http.Request) models.RequestInfo {
+	return models.RequestInfo{
+		IP:        middleware.GetClientIP(r),
+		Referrer:  r.Referer(),
+		UserAgent: r.UserAgent(),
+		Query:     r.URL.RawQuery,
+
+		AcceptLanguage: r.Header.Get("Accept-Language"),
+	}
+}
+
+func parseTimeParam(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// createErrorStatus mapeia erros de criação para o status HTTP correspondente
+func createErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrAliasInUse):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrURLRequired),
+		errors.Is(err, service.ErrInvalidURL),
+		errors.Is(err, service.ErrSchemeNotAllowed),
+		errors.Is(err, service.ErrCredentialsInURL),
+		errors.Is(err, service.ErrPrivateHost),
+		errors.Is(err, service.ErrSelfReference),
+		errors.Is(err, service.ErrBlockedDomain),
+		errors.Is(err, service.ErrInvalidAlias),
+		errors.Is(err, service.ErrReservedAlias),
+		errors.Is(err, service.ErrInvalidCodeLength),
+		errors.Is(err, service.ErrInvalidExpiration),
+		errors.Is(err, service.ErrInvalidMaxClicks),
+		errors.Is(err, service.ErrInvalidRedirectType),
+		errors.Is(err, service.ErrInvalidOpenGraph),
+		errors.Is(err, service.ErrInvalidUTM),
+		errors.Is(err, service.ErrInvalidRule),
+		errors.Is(err, service.ErrUnknownDomain),
+		errors.Is(err, service.ErrInvalidPassword),
+		errors.Is(err, service.ErrPasswordRequiresAccount):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrKeyspaceExhausted):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
 }
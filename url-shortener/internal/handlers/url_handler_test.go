@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"url-shortener/internal/middleware"
+	"url-shortener/internal/models"
+	"url-shortener/internal/repository"
+	"url-shortener/internal/service"
+)
+
+func TestStatsHidesOwnerFieldsFromAnonymous(t *testing.T) {
+	store := repository.NewMemoryStore()
+	urlService := service.NewURLService(store, "https://sho.rt")
+	urlService.Validator().SetResolver(nil)
+	accounts := service.NewAccountService(store)
+
+	account, err := accounts.CreateAccount("time")
+	if err != nil {
+		t.Fatal(err)
+	}
+	created, err := urlService.CreateShortURL(models.CreateURLRequest{
+		URL:    "https://old.example.com/pagina",
+		Alias:  "promo",
+		Dedupe: true,
+		Rules:  []models.RoutingRule{{Destination: "https://m.example.com", Devices: []string{"mobile"}}},
+	}, account.Account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	destination := "https://new.example.com/pagina"
+	if _, err := urlService.UpdateLink(account.Account.ID, "promo", models.UpdateURLRequest{URL: &destination}); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := middleware.APIKeyAuth(accounts)(http.HandlerFunc(NewURLHandler(urlService).GetStats))
+	stats := func(apiKey string) (link map[string]any, body string) {
+		req := httptest.NewRequest(http.MethodGet, "/stats/promo", nil)
+		if apiKey != "" {
+			req.Header.Set("X-API-Key", apiKey)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status %d: %s", rec.Code, rec.Body)
+		}
+		var resp struct {
+			URL map[string]any `json:"url"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp.URL, rec.Body.String()
+	}
+
+	link, body := stats("")
+	for _, field := range []string{"owner_id", "dedupe_key", "rules", "health", "history"} {
+		if _, ok := link[field]; ok {
+			t.Errorf("resposta anônima contém %s: %v", field, link)
+		}
+	}
+	for _, leak := range []string{`"changed_by"`, created.OriginalURL} {
+		if strings.Contains(body, leak) {
+			t.Errorf("resposta anônima contém %s", leak)
+		}
+	}
+	if link["original_url"] != destination {
+		t.Errorf("original_url = %v, esperado %s", link["original_url"], destination)
+	}
+
+	link, body = stats(account.APIKey)
+	for _, field := range []string{"owner_id", "dedupe_key", "rules", "history"} {
+		if _, ok := link[field]; !ok {
+			t.Errorf("resposta do dono sem %s", field)
+		}
+	}
+	if !strings.Contains(body, `"changed_by"`) || !strings.Contains(body, created.OriginalURL) {
+		t.Error("resposta do dono sem o histórico de destinos")
+	}
+}
+
+func TestPostToUnprotectedLinkIsNotAClick(t *testing.T) {
+	store := repository.NewMemoryStore()
+	urlService := service.NewURLService(store, "https://sho.rt")
+	urlService.Validator().SetResolver(nil)
+	if _, err := urlService.CreateShortURL(models.CreateURLRequest{URL: "https://example.com", Alias: "aberto"}, ""); err != nil {
+		t.Fatal(err)
+	}
+	handler := NewURLHandler(urlService)
+
+	for _, path := range []string{"/aberto", "/aberto+"} {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader("password=x"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		handler.RedirectURL(rec, req)
+		if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != http.MethodGet {
+			t.Errorf("POST %s: status %d, Allow %q; esperado 405 com Allow: GET", path, rec.Code, rec.Header().Get("Allow"))
+		}
+	}
+
+	url, err := store.FindByShortCode("aberto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url.Clicks != 0 {
+		t.Errorf("POST registrou %d cliques", url.Clicks)
+	}
+}
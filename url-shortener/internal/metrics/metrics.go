@@ -0,0 +1,46 @@
+package metrics
+
+import "runtime"
+
+// Default é o registro servido em /metrics
+var Default = NewRegistry()
+
+// Métricas da aplicação
+var (
+	HTTPRequests = Default.NewCounter(
+		"urlshortener_http_requests_total",
+		"Requisições HTTP por rota, método e status.",
+		"route", "method", "status",
+	)
+	HTTPDuration = Default.NewHistogram(
+		"urlshortener_http_request_duration_seconds",
+		"Latência das requisições HTTP por rota.",
+		DefBuckets,
+		"route", "method",
+	)
+	Redirects = Default.NewCounter(
+		"urlshortener_redirects_total",
+		"Redirecionamentos servidos por status HTTP.",
+		"status",
+	)
+	LinksCreated = Default.NewCounter(
+		"urlshortener_links_created_total",
+		"Links criados (não inclui links reaproveitados pela deduplicação).",
+	)
+	RateLimitRejections = Default.NewCounter(
+		"urlshortener_rate_limit_rejections_total",
+		"Requisições recusadas pelo limite de requisições, por limitador.",
+		"limiter",
+	)
+	LinkChecks = Default.NewCounter(
+		"urlshortener_link_checks_total",
+		"Verificações de destino por resultado (ok ou broken).",
+		"result",
+	)
+)
+
+func init() {
+	Default.NewGaugeFunc("go_goroutines", "Número de goroutines em execução.", func() float64 {
+		return float64(runtime.NumGoroutine())
+	})
+}
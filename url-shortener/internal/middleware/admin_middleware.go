@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+)
+
+// RequireAdmin exige o token de administração no header X-Admin-Token.
+// Com token vazio as rotas administrativas ficam desativadas.
+func RequireAdmin(token string) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if token == "" {
+				http.Error(w, "Administração desativada", http.StatusForbidden)
+				return
+			}
+
+			provided := r.Header.Get("X-Admin-Token")
+			if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+				http.Error(w, "Token de administração inválido", http.StatusUnauthorized)
+				return
+			}
+
+			next(w, r)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"url-shortener/internal/models"
+	"url-shortener/internal/service"
+)
+
+type contextKey string
+
+const ContextAccount contextKey = "account"
+
+// APIKeyAuth identifica a conta pela chave de API enviada em
+// "Authorization: Bearer <chave>" ou "X-API-Key". Requisições sem chave
+// seguem anônimas; chaves inválidas são rejeitadas.
+func APIKeyAuth(accounts *service.AccountService) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := apiKeyFromRequest(r)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			account, err := accounts.Authenticate(key)
+			if err != nil {
+				http.Error(w, "Chave de API inválida", http.StatusUnauthorized)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), ContextAccount, account)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequireAccount rejeita requisições sem chave de API
+func RequireAccount(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if GetAccount(r) == nil {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Chave de API obrigatória", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func GetAccount(r *http.Request) *models.Account {
+	account, _ := r.Context().Value(ContextAccount).(*models.Account)
+	return account
+}
+
+// GetAccountID retorna "" para requisições anônimas
+func GetAccountID(r *http.Request) string {
+	if account := GetAccount(r); account != nil {
+		return account.ID
+	}
+	return ""
+}
+
+func apiKeyFromRequest(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+
+	parts := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
+	if len(parts) == 2 && strings.EqualFold(parts[0], "bearer") {
+		return strings.TrimSpace(parts[1])
+	}
+	return ""
+}
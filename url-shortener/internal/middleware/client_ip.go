@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const ContextClientIP contextKey = "client_ip"
+
+// IPResolver descobre o IP real do cliente. O X-Forwarded-For só é
+// considerado quando a conexão vem de um proxy confiável, e é percorrido da
+// direita para a esquerda até o primeiro endereço não confiável.
+type IPResolver struct {
+	trusted []*net.IPNet
+}
+
+// NewIPResolver aceita IPs ou faixas CIDR dos proxies confiáveis
+func NewIPResolver(proxies []string) (*IPResolver, error) {
+	r := &IPResolver{}
+	for _, proxy := range proxies {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+		if !strings.Contains(proxy, "/") {
+			if ip := net.ParseIP(proxy); ip != nil && ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("proxy confiável inválido %q: %w", proxy, err)
+		}
+		r.trusted = append(r.trusted, network)
+	}
+	return r, nil
+}
+
+func (r *IPResolver) ClientIP(req *http.Request) string {
+	remote := remoteIP(req)
+	if !r.isTrusted(remote) {
+		return remote
+	}
+
+	hops := forwardedFor(req)
+	for i := len(hops) - 1; i >= 0; i-- {
+		if !r.isTrusted(hops[i]) {
+			return hops[i]
+		}
+	}
+	if len(hops) > 0 {
+		return hops[0]
+	}
+	return remote
+}
+
+func (r *IPResolver) isTrusted(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range r.trusted {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP guarda o IP real do cliente no contexto da requisição
+func ClientIP(resolver *IPResolver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), ContextClientIP, resolver.ClientIP(r))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// GetClientIP retorna o IP resolvido pelo middleware ClientIP ou, na
+// ausência dele, o endereço da conexão
+func GetClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(ContextClientIP).(string); ok {
+		return ip
+	}
+	return remoteIP(r)
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func forwardedFor(r *http.Request) []string {
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(header, ",") {
+			if hop = strings.TrimSpace(hop); net.ParseIP(hop) != nil {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	return hops
+}
@@ -0,0 +1,147 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+	"url-shortener/internal/metrics"
+)
+
+const janitorInterval = time.Minute
+
+// RateLimiter aplica um token bucket por cliente: a conta da chave de API
+// quando autenticado, senão o IP real do cliente
+type RateLimiter struct {
+	name    string  // identifica o limitador nas métricas
+	rate    float64 // tokens por segundo
+	burst   float64
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter permite perMinute requisições por minuto com rajadas de até
+// burst requisições. perMinute <= 0 desativa o limite.
+func NewRateLimiter(name string, perMinute, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+
+	l := &RateLimiter{
+		name:    name,
+		rate:    float64(perMinute) / 60,
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+	}
+	if perMinute > 0 {
+		go l.janitor()
+	}
+	return l
+}
+
+// Limit cobra um token por requisição
+func (l *RateLimiter) Limit(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if l.Take(w, r, 1) {
+			next(w, r)
+		}
+	}
+}
+
+// Take consome n tokens do cliente da requisição, para handlers cujo custo
+// só é conhecido depois de ler o corpo (como o número de linhas de um lote).
+// Sem tokens suficientes responde 429 e retorna false.
+func (l *RateLimiter) Take(w http.ResponseWriter, r *http.Request, n int) bool {
+	if l.rate <= 0 || n <= 0 {
+		return true
+	}
+
+	key := "ip:" + GetClientIP(r)
+	if accountID := GetAccountID(r); accountID != "" {
+		key = "account:" + accountID
+	}
+
+	allowed, remaining, retryAfter, reset := l.take(key, float64(n), time.Now())
+
+	h := w.Header()
+	h.Set("RateLimit-Policy", strconv.Itoa(int(l.burst))+";w="+strconv.Itoa(int(math.Ceil(l.burst/l.rate))))
+	h.Set("RateLimit-Limit", strconv.Itoa(int(l.burst)))
+	h.Set("RateLimit-Remaining", strconv.Itoa(remaining))
+	h.Set("RateLimit-Reset", strconv.Itoa(seconds(reset)))
+
+	if !allowed {
+		h.Set("Retry-After", strconv.Itoa(seconds(retryAfter)))
+		metrics.RateLimitRejections.Inc(l.name)
+		http.Error(w, "Limite de requisições excedido", http.StatusTooManyRequests)
+		return false
+	}
+	return true
+}
+
+// Burst é o maior custo que uma única requisição pode ter; zero quando o
+// limite está desativado
+func (l *RateLimiter) Burst() int {
+	if l.rate <= 0 {
+		return 0
+	}
+	return int(l.burst)
+}
+
+// take consome n tokens do cliente. Retorna se a requisição foi aceita, os
+// tokens restantes, o tempo até haver n tokens e o tempo até o bucket encher.
+func (l *RateLimiter) take(key string, n float64, now time.Time) (bool, int, time.Duration, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, exists := l.buckets[key]
+	if !exists {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	allowed := b.tokens >= n
+	if allowed {
+		b.tokens -= n
+	}
+
+	retryAfter := time.Duration(0)
+	if b.tokens < n {
+		retryAfter = l.durationFor(n - b.tokens)
+	}
+	reset := l.durationFor(l.burst - b.tokens)
+
+	return allowed, int(b.tokens), retryAfter, reset
+}
+
+func (l *RateLimiter) durationFor(tokens float64) time.Duration {
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
+
+// janitor descarta os buckets cheios, que equivalem a um cliente novo
+func (l *RateLimiter) janitor() {
+	ticker := time.NewTicker(janitorInterval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		l.mu.Lock()
+		for key, b := range l.buckets {
+			if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+				delete(l.buckets, key)
+			}
+		}
+		l.mu.Unlock()
+	}
+}
+
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterRefillsOverTime(t *testing.T) {
+	l := NewRateLimiter("test", 60, 2) // um token por segundo
+	now := time.Now()
+
+	for i := range 2 {
+		if ok, _, _, _ := l.take("ip:1", 1, now); !ok {
+			t.Fatalf("requisição %d da rajada recusada", i+1)
+		}
+	}
+	ok, remaining, retryAfter, reset := l.take("ip:1", 1, now)
+	if ok {
+		t.Fatal("requisição além da rajada aceita")
+	}
+	if remaining != 0 || retryAfter != time.Second || reset != 2*time.Second {
+		t.Errorf("remaining=%d retryAfter=%v reset=%v, esperado 0, 1s e 2s", remaining, retryAfter, reset)
+	}
+
+	// Outro cliente tem o próprio bucket
+	if ok, _, _, _ := l.take("ip:2", 1, now); !ok {
+		t.Error("cliente novo recusado")
+	}
+
+	if ok, _, _, _ := l.take("ip:1", 1, now.Add(time.Second)); !ok {
+		t.Error("token reposto após 1s recusado")
+	}
+}
+
+func TestRateLimiterChargesCost(t *testing.T) {
+	l := NewRateLimiter("test", 60, 10)
+	now := time.Now()
+
+	if ok, remaining, _, _ := l.take("ip:1", 7, now); !ok || remaining != 3 {
+		t.Fatalf("ok=%v remaining=%d, esperado true e 3", ok, remaining)
+	}
+	// Sem tokens para o custo inteiro nada é consumido
+	ok, remaining, retryAfter, _ := l.take("ip:1", 5, now)
+	if ok || remaining != 3 {
+		t.Fatalf("ok=%v remaining=%d, esperado false e 3", ok, remaining)
+	}
+	if retryAfter != 2*time.Second {
+		t.Errorf("retryAfter = %v, esperado o tempo até haver 5 tokens (2s)", retryAfter)
+	}
+	if ok, _, _, _ := l.take("ip:1", 3, now); !ok {
+		t.Error("custo igual aos tokens restantes recusado")
+	}
+}
+
+func TestRateLimiterTake(t *testing.T) {
+	l := NewRateLimiter("test", 60, 5)
+	request := func() *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/shorten/batch", nil)
+		r.RemoteAddr = "203.0.113.7:4321"
+		return r
+	}
+
+	w := httptest.NewRecorder()
+	if !l.Take(w, request(), 4) {
+		t.Fatal("custo dentro da rajada recusado")
+	}
+	if got := w.Header().Get("RateLimit-Remaining"); got != "1" {
+		t.Errorf("RateLimit-Remaining = %q, esperado 1", got)
+	}
+
+	w = httptest.NewRecorder()
+	if l.Take(w, request(), 2) {
+		t.Fatal("custo acima dos tokens restantes aceito")
+	}
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("status = %d, esperado 429", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("Retry-After ausente")
+	}
+
+	if l.Burst() != 5 {
+		t.Errorf("Burst() = %d, esperado 5", l.Burst())
+	}
+}
+
+func TestRateLimiterDisabled(t *testing.T) {
+	l := NewRateLimiter("test", 0, 1)
+
+	called := 0
+	handler := l.Limit(func(http.ResponseWriter, *http.Request) { called++ })
+	for range 100 {
+		handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+	if called != 100 {
+		t.Errorf("handler chamado %d vezes, esperado 100", called)
+	}
+	if l.Burst() != 0 {
+		t.Errorf("Burst() = %d, esperado 0 com o limite desativado", l.Burst())
+	}
+}
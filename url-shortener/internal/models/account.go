@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+type Account struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	APIKeys   []APIKey  `json:"api_keys"`
+}
+
+// APIKey guarda apenas o hash da chave; a chave em texto puro só é
+// devolvida no momento da criação
+type APIKey struct {
+	ID        string    `json:"id"`
+	Prefix    string    `json:"prefix"` // início da chave, para identificação
+	Hash      string    `json:"hash"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type CreateAccountRequest struct {
+	Name string `json:"name"`
+}
+
+type APIKeyInfo struct {
+	ID        string    `json:"id"`
+	Prefix    string    `json:"prefix"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type AccountResponse struct {
+	ID        string       `json:"id"`
+	Name      string       `json:"name"`
+	CreatedAt time.Time    `json:"created_at"`
+	APIKeys   []APIKeyInfo `json:"api_keys"`
+}
+
+// CreateAPIKeyResponse é a única resposta que contém a chave em texto puro
+type CreateAPIKeyResponse struct {
+	Account *AccountResponse `json:"account"`
+	KeyID   string           `json:"key_id"`
+	APIKey  string           `json:"api_key"`
+}
+
+// ToResponse remove os hashes das chaves
+func (a *Account) ToResponse() *AccountResponse {
+	keys := make([]APIKeyInfo, 0, len(a.APIKeys))
+	for _, key := range a.APIKeys {
+		keys = append(keys, APIKeyInfo{ID: key.ID, Prefix: key.Prefix, CreatedAt: key.CreatedAt})
+	}
+
+	return &AccountResponse{
+		ID:        a.ID,
+		Name:      a.Name,
+		CreatedAt: a.CreatedAt,
+		APIKeys:   keys,
+	}
+}
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// BatchResult é o resultado de uma linha de POST /shorten/batch
+type BatchResult struct {
+	Row         int    `json:"row"`
+	Status      int    `json:"status"`
+	ShortURL    string `json:"short_url,omitempty"`
+	OriginalURL string `json:"original_url,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+type BatchResponse struct {
+	Results []BatchResult `json:"results"`
+	Created int           `json:"created"`
+	Failed  int           `json:"failed"`
+}
+
+// LinkExport é uma linha de GET /links/export
+type LinkExport struct {
+	ShortCode      string     `json:"short_code"`
+	ShortURL       string     `json:"short_url"`
+	OriginalURL    string     `json:"original_url"`
+	CreatedAt      time.Time  `json:"created_at"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	MaxClicks      int        `json:"max_clicks,omitempty"`
+	Clicks         int        `json:"clicks"`
+	UniqueVisitors int        `json:"unique_visitors"`
+	LastClickAt    *time.Time `json:"last_click_at,omitempty"`
+	Domain         string     `json:"domain,omitempty"`
+}
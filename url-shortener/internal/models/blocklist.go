@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// BlockedDomain é um padrão de host bloqueado (ex.: "evil.com", "*.evil.com")
+type BlockedDomain struct {
+	Pattern   string    `json:"pattern"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
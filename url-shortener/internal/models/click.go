@@ -0,0 +1,66 @@
+package models
+
+import "time"
+
+// ClickEvent registra um redirecionamento de um link curto
+type ClickEvent struct {
+	ShortCode string    `json:"short_code"`
+	Domain    string    `json:"domain,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	Referrer  string    `json:"referrer,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	Country   string    `json:"country,omitempty"`
+	Device    string    `json:"device"`
+	VisitorID string    `json:"visitor_id"`        // hash de IP + user agent, o IP não é armazenado
+	Rule      string    `json:"rule,omitempty"`    // regra de roteamento aplicada
+	Variant   string    `json:"variant,omitempty"` // variante da divisão A/B
+	Version   int       `json:"version,omitempty"` // versão do destino; zero equivale a 1
+}
+
+// LinkKey retorna a chave do link clicado no armazenamento
+func (e *ClickEvent) LinkKey() string {
+	return LinkKey(e.Domain, e.ShortCode)
+}
+
+// RequestInfo reúne os dados da requisição de redirecionamento usados nas estatísticas
+type RequestInfo struct {
+	IP        string
+	Referrer  string
+	UserAgent string
+	Query     string // query string recebida na URL curta
+
+	AcceptLanguage string
+}
+
+// VersionCount são os cliques de uma versão do destino
+type VersionCount struct {
+	Version     int       `json:"version"`
+	Destination string    `json:"destination,omitempty"` // vazio nas versões anteriores, exceto para o dono
+	Since       time.Time `json:"since"`
+	Clicks      int       `json:"clicks"`
+}
+
+type URLStats struct {
+	URL            *URL           `json:"url"`
+	From           time.Time      `json:"from"`
+	To             time.Time      `json:"to"`
+	TotalClicks    int            `json:"total_clicks"`
+	UniqueVisitors int            `json:"unique_visitors"`
+	Hourly         []TimeBucket   `json:"hourly"`
+	Daily          []TimeBucket   `json:"daily"`
+	TopReferrers   []CountEntry   `json:"top_referrers"`
+	Countries      []CountEntry   `json:"countries"`
+	Devices        []CountEntry   `json:"devices"`
+	Rules          []CountEntry   `json:"rules"` // "default" quando nenhuma regra casou
+	Versions       []VersionCount `json:"versions"`
+}
+
+type TimeBucket struct {
+	Start  time.Time `json:"start"`
+	Clicks int       `json:"clicks"`
+}
+
+type CountEntry struct {
+	Value  string `json:"value"`
+	Clicks int    `json:"clicks"`
+}
@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// RoutingRule envia o visitante para outro destino quando todas as condições
+// preenchidas são satisfeitas. As regras são avaliadas em ordem e a primeira
+// que casar vence; sem regra correspondente o destino é OriginalURL.
+type RoutingRule struct {
+	ID          string                `json:"id"` // identificador nas estatísticas; gerado quando vazio
+	Destination string                `json:"destination,omitempty"`
+	Split       []WeightedDestination `json:"split,omitempty"` // divisão A/B no lugar de destination
+
+	Devices    []string    `json:"devices,omitempty"`   // desktop, mobile, tablet, bot
+	OS         []string    `json:"os,omitempty"`        // ios, android, windows, macos, linux, chromeos
+	Languages  []string    `json:"languages,omitempty"` // "pt" casa com "pt-BR"; "pt-BR" só com "pt-BR"
+	Countries  []string    `json:"countries,omitempty"` // códigos ISO 3166-1 alpha-2
+	TimeWindow *TimeWindow `json:"time_window,omitempty"`
+}
+
+// WeightedDestination é uma variante da divisão A/B; a escolha é estável
+// por visitante
+type WeightedDestination struct {
+	Label       string `json:"label"` // gerado ("a", "b", ...) quando vazio
+	Destination string `json:"destination"`
+	Weight      int    `json:"weight"`
+}
+
+// TimeWindow é o intervalo [start, end) em que a regra vale; um dos
+// limites pode ser omitido
+type TimeWindow struct {
+	Start *time.Time `json:"start,omitempty"`
+	End   *time.Time `json:"end,omitempty"`
+}
+
+// Contains indica se o instante está dentro da janela
+func (w *TimeWindow) Contains(t time.Time) bool {
+	if w.Start != nil && t.Before(*w.Start) {
+		return false
+	}
+	return w.End == nil || t.Before(*w.End)
+}
@@ -3,18 +3,225 @@ package models
 import "time"
 
 type URL struct {
-	ID          string    `json:"id"`
-	OriginalURL string    `json:"original_url"`
-	ShortCode   string    `json:"short_code"`
-	CreatedAt   time.Time `json:"created_at"`
-	Clicks      int       `json:"clicks"`
+	ID          string     `json:"id"`
+	OriginalURL string     `json:"original_url"`
+	ShortCode   string     `json:"short_code"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Clicks      int        `json:"clicks"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	MaxClicks   int        `json:"max_clicks,omitempty"`
+	OwnerID     string     `json:"owner_id,omitempty"`
+	Domain      string     `json:"domain,omitempty"`     // host do domínio adicional; vazio no domínio principal
+	DedupeKey   string     `json:"dedupe_key,omitempty"` // destino normalizado, usado na deduplicação
+	Sequence    uint64     `json:"sequence,omitempty"`   // número do gerador sequencial que produziu o código
+
+	RedirectType int        `json:"redirect_type,omitempty"` // 301, 302, 307 ou 308; zero usa 302
+	OpenGraph    *OpenGraph `json:"open_graph,omitempty"`
+	UTM          *UTMParams `json:"utm,omitempty"`
+	ForwardQuery bool       `json:"forward_query,omitempty"` // repassa a query da URL curta ao destino
+
+	Rules []RoutingRule `json:"rules,omitempty"` // roteamento condicional, avaliado em ordem
+
+	// Links protegidos só redirecionam após a senha; as estatísticas exigem
+	// a chave de API do dono
+	PasswordHash string `json:"password_hash,omitempty"` // bcrypt
+	Protected    bool   `json:"protected,omitempty"`     // preenchido apenas nas respostas da API
+
+	Health *LinkHealth `json:"health,omitempty"` // última verificação do destino
+
+	// Versão atual do destino (zero equivale a 1) e, a partir da primeira
+	// alteração, todas as versões, inclusive a atual
+	Version int                  `json:"version,omitempty"`
+	History []DestinationVersion `json:"history,omitempty"`
+}
+
+// DestinationVersion é uma versão do destino do link
+type DestinationVersion struct {
+	Version   int       `json:"version"`
+	URL       string    `json:"url"`
+	ChangedAt time.Time `json:"changed_at"`
+	ChangedBy string    `json:"changed_by,omitempty"` // conta que fez a alteração
+}
+
+// DestinationVersion retorna a versão atual do destino
+func (u *URL) DestinationVersion() int {
+	return max(u.Version, 1)
+}
+
+// Versions retorna o histórico de destinos, da primeira versão à atual
+func (u *URL) Versions() []DestinationVersion {
+	if len(u.History) > 0 {
+		return u.History
+	}
+	return []DestinationVersion{{
+		Version:   1,
+		URL:       u.OriginalURL,
+		ChangedAt: u.CreatedAt,
+		ChangedBy: u.OwnerID,
+	}}
+}
+
+// LinkHealth é o resultado da verificação periódica do destino do link
+type LinkHealth struct {
+	StatusCode  int        `json:"status_code,omitempty"` // zero quando a requisição falhou
+	Error       string     `json:"error,omitempty"`
+	CheckedAt   time.Time  `json:"checked_at"`
+	LastOKAt    *time.Time `json:"last_ok_at,omitempty"`
+	Failures    int        `json:"failures,omitempty"` // falhas consecutivas
+	NextCheckAt time.Time  `json:"next_check_at"`
+}
+
+// Broken indica se a última verificação do destino falhou
+func (h *LinkHealth) Broken() bool {
+	return h != nil && h.Failures > 0
+}
+
+// UTMParams são os parâmetros de campanha acrescentados ao destino
+type UTMParams struct {
+	Source   string `json:"source,omitempty"`
+	Medium   string `json:"medium,omitempty"`
+	Campaign string `json:"campaign,omitempty"`
+	Term     string `json:"term,omitempty"`
+	Content  string `json:"content,omitempty"`
+}
+
+// OpenGraph são os metadados servidos aos crawlers de redes sociais no
+// lugar do redirecionamento
+type OpenGraph struct {
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Image       string `json:"image,omitempty"`
+}
+
+// Redirect é o resultado da resolução de um link curto. Com OpenGraph
+// preenchido a resposta deve ser a página de metadados, não o redirecionamento.
+type Redirect struct {
+	Destination string
+	Status      int
+	ShortURL    string
+	OpenGraph   *OpenGraph
+}
+
+// LinkKey é a chave do link no armazenamento: o código no domínio principal
+// e "domínio/código" nos domínios adicionais, para que o mesmo código possa
+// existir em domínios diferentes
+func LinkKey(domain, code string) string {
+	if domain == "" {
+		return code
+	}
+	return domain + "/" + code
+}
+
+// Key retorna a chave do link no armazenamento
+func (u *URL) Key() string {
+	return LinkKey(u.Domain, u.ShortCode)
+}
+
+// IsProtected indica se o link exige senha
+func (u *URL) IsProtected() bool {
+	return u.PasswordHash != ""
+}
+
+// Public retorna uma cópia sem o hash da senha, para as respostas da API
+func (u *URL) Public() *URL {
+	public := *u
+	public.Protected = u.IsProtected()
+	public.PasswordHash = ""
+	return &public
+}
+
+// StatsView retorna apenas os campos públicos do link, para quem consulta
+// as estatísticas sem ser o dono: sem dono, chave de deduplicação, regras,
+// verificação do destino e histórico
+func (u *URL) StatsView() *URL {
+	return &URL{
+		ID:           u.ID,
+		OriginalURL:  u.OriginalURL,
+		ShortCode:    u.ShortCode,
+		CreatedAt:    u.CreatedAt,
+		Clicks:       u.Clicks,
+		ExpiresAt:    u.ExpiresAt,
+		MaxClicks:    u.MaxClicks,
+		Domain:       u.Domain,
+		RedirectType: u.RedirectType,
+		Protected:    u.IsProtected(),
+		Version:      u.Version,
+	}
+}
+
+// IsExpired indica se o link passou da data de expiração ou esgotou o limite de cliques
+func (u *URL) IsExpired(now time.Time) bool {
+	if u.ExpiresAt != nil && !now.Before(*u.ExpiresAt) {
+		return true
+	}
+	return u.MaxClicks > 0 && u.Clicks >= u.MaxClicks
 }
 
 type CreateURLRequest struct {
-	URL string `json:"url" binding:"required"`
+	URL        string     `json:"url" binding:"required"`
+	Alias      string     `json:"alias,omitempty"`       // código personalizado (opcional)
+	CodeLength int        `json:"code_length,omitempty"` // tamanho do código gerado (opcional)
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`  // data de expiração (opcional)
+	MaxClicks  int        `json:"max_clicks,omitempty"`  // limite de cliques (opcional)
+	Dedupe     bool       `json:"dedupe,omitempty"`      // reutilizar link existente para o mesmo destino
+	Domain     string     `json:"domain,omitempty"`      // domínio do link curto (opcional, padrão é o principal)
+
+	RedirectType int        `json:"redirect_type,omitempty"` // 301, 302 (padrão), 307 ou 308
+	OpenGraph    *OpenGraph `json:"open_graph,omitempty"`    // metadados para redes sociais (opcional)
+	UTM          *UTMParams `json:"utm,omitempty"`           // parâmetros de campanha (opcional)
+	ForwardQuery bool       `json:"forward_query,omitempty"` // repassar a query da URL curta ao destino
+
+	Rules []RoutingRule `json:"rules,omitempty"` // regras de roteamento (opcional)
+
+	Password string `json:"password,omitempty"` // senha exigida antes do redirecionamento (opcional, requer conta)
+}
+
+// UpdateURLRequest altera apenas os campos enviados
+type UpdateURLRequest struct {
+	URL              *string    `json:"url,omitempty"` // novo destino; o código e as estatísticas são mantidos
+	ExpiresAt        *time.Time `json:"expires_at,omitempty"`
+	RemoveExpiration bool       `json:"remove_expiration,omitempty"` // remove a data de expiração
+	MaxClicks        *int       `json:"max_clicks,omitempty"`        // 0 remove o limite
+	RedirectType     *int       `json:"redirect_type,omitempty"`     // 0 volta ao padrão (302)
+	OpenGraph        *OpenGraph `json:"open_graph,omitempty"`
+	RemoveOpenGraph  bool       `json:"remove_open_graph,omitempty"`
+	UTM              *UTMParams `json:"utm,omitempty"` // substitui todos os campos UTM
+	RemoveUTM        bool       `json:"remove_utm,omitempty"`
+	ForwardQuery     *bool      `json:"forward_query,omitempty"`
+
+	Rules *[]RoutingRule `json:"rules,omitempty"` // substitui todas as regras; [] remove
+
+	Password *string `json:"password,omitempty"` // "" remove a senha
+}
+
+// KeyspaceUsage é a ocupação do espaço de códigos do tamanho padrão
+type KeyspaceUsage struct {
+	Strategy     string  `json:"strategy"`
+	AlphabetSize int     `json:"alphabet_size"`
+	Length       int     `json:"length"`
+	Capacity     float64 `json:"capacity"` // alfabeto^tamanho
+	Used         uint64  `json:"used"`
+	Ratio        float64 `json:"ratio"`
+}
+
+type ListURLsResponse struct {
+	Links  []*URL `json:"links"`
+	Total  int    `json:"total"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
 }
 
 type CreateURLResponse struct {
-	ShortURL    string `json:"short_url"`
-	OriginalURL string `json:"original_url"`
+	ShortURL     string        `json:"short_url"`
+	Domain       string        `json:"domain,omitempty"`
+	QRURL        string        `json:"qr_url"`
+	OriginalURL  string        `json:"original_url"`
+	ExpiresAt    *time.Time    `json:"expires_at,omitempty"`
+	MaxClicks    int           `json:"max_clicks,omitempty"`
+	RedirectType int           `json:"redirect_type"`
+	UTM          *UTMParams    `json:"utm,omitempty"`
+	ForwardQuery bool          `json:"forward_query,omitempty"`
+	Rules        []RoutingRule `json:"rules,omitempty"`
+	Protected    bool          `json:"protected,omitempty"`
+	Existing     bool          `json:"existing,omitempty"` // link já existente retornado pela deduplicação
 }
@@ -0,0 +1,498 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"url-shortener/internal/models"
+)
+
+const (
+	opSave     = "save"
+	opClick    = "click"
+	opDelete   = "delete"
+	opPurge    = "purge" // link expirado removido; a chave continua reservada
+	opEvent    = "event" // clique já contabilizado no snapshot
+	opAccount  = "account"
+	opBlock    = "block"
+	opUnblock  = "unblock"
+	opSequence = "sequence" // maior número de sequência já usado por um link
+
+	// Compactar quando o log tiver pelo menos compactMinRecords registros e
+	// mais que compactRatio registros por URL viva
+	compactMinRecords = 1000
+	compactRatio      = 2
+)
+
+// logRecord é uma linha do log append-only
+type logRecord struct {
+	Op       string                `json:"op"`
+	URL      *models.URL           `json:"url,omitempty"`
+	Code     string                `json:"code,omitempty"`
+	Click    *models.ClickEvent    `json:"click,omitempty"`
+	Account  *models.Account       `json:"account,omitempty"`
+	Blocked  *models.BlockedDomain `json:"blocked,omitempty"`
+	Sequence uint64                `json:"sequence,omitempty"`
+}
+
+// FileStore mantém as URLs em memória e registra cada alteração em um log
+// append-only (um JSON por linha), sincronizado com o disco antes de cada
+// alteração ser confirmada. Na inicialização o log é reproduzido e
+// compactado em um snapshot com um registro por URL.
+type FileStore struct {
+	mu      sync.Mutex // serializa escritas no log e no estado em memória
+	path    string
+	file    *os.File
+	mem     *MemoryStore
+	records int // registros no log desde a última compactação
+}
+
+func NewFileStore(path string) (*FileStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	s := &FileStore{
+		path: path,
+		mem:  NewMemoryStore(),
+	}
+
+	if err := s.replay(); err != nil {
+		return nil, err
+	}
+
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *FileStore) Save(url *models.URL) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.maybeCompact()
+
+	if err := s.append(logRecord{Op: opSave, URL: url}); err != nil {
+		return err
+	}
+	return s.mem.Save(url)
+}
+
+func (s *FileStore) Update(code string, fn func(url *models.URL) error) (*models.URL, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.maybeCompact()
+
+	current, err := s.mem.FindByShortCode(code)
+	if err != nil {
+		return nil, err
+	}
+
+	// Cliques só são gravados com s.mu travado, então a cópia é consistente
+	updated := *current
+	if err := fn(&updated); err != nil {
+		return nil, err
+	}
+
+	if err := s.append(logRecord{Op: opSave, URL: &updated}); err != nil {
+		return nil, err
+	}
+	s.mem.Save(&updated)
+	return &updated, nil
+}
+
+func (s *FileStore) FindByShortCode(code string) (*models.URL, error) {
+	return s.mem.FindByShortCode(code)
+}
+
+func (s *FileStore) RecordClick(event *models.ClickEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.maybeCompact()
+
+	if _, err := s.mem.FindByShortCode(event.LinkKey()); err != nil {
+		return err
+	}
+
+	if err := s.append(logRecord{Op: opClick, Click: event}); err != nil {
+		return err
+	}
+	return s.mem.RecordClick(event)
+}
+
+// RecordClicks grava um lote de cliques com uma única escrita no log;
+// cliques de links removidos são descartados
+func (s *FileStore) RecordClicks(events []*models.ClickEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.maybeCompact()
+
+	var buf bytes.Buffer
+	recorded := make([]*models.ClickEvent, 0, len(events))
+	for _, event := range events {
+		if _, err := s.mem.FindByShortCode(event.LinkKey()); err != nil {
+			continue
+		}
+		data, err := json.Marshal(logRecord{Op: opClick, Click: event})
+		if err != nil {
+			return err
+		}
+		buf.Write(append(data, '\n'))
+		recorded = append(recorded, event)
+	}
+	if len(recorded) == 0 {
+		return nil
+	}
+
+	if err := s.write(buf.Bytes()); err != nil {
+		return err
+	}
+	s.records += len(recorded)
+
+	for _, event := range recorded {
+		s.mem.RecordClick(event)
+	}
+	return nil
+}
+
+func (s *FileStore) GetClicks(code string, from, to time.Time) []*models.ClickEvent {
+	return s.mem.GetClicks(code, from, to)
+}
+
+func (s *FileStore) FindByDestination(ownerID, dedupeKey string) []*models.URL {
+	return s.mem.FindByDestination(ownerID, dedupeKey)
+}
+
+func (s *FileStore) GetAll() []*models.URL {
+	return s.mem.GetAll()
+}
+
+func (s *FileStore) Delete(code string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.maybeCompact()
+
+	if _, err := s.mem.FindByShortCode(code); err != nil {
+		return err
+	}
+
+	if err := s.append(logRecord{Op: opDelete, Code: code}); err != nil {
+		return err
+	}
+	return s.mem.Delete(code)
+}
+
+// PurgeExpired remove os links expirados há mais de retention, mantendo as
+// chaves reservadas, e retorna quantos foram removidos
+func (s *FileStore) PurgeExpired(now time.Time, retention time.Duration) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.maybeCompact()
+
+	removed := 0
+	for _, code := range s.mem.purgeableCodes(now, retention) {
+		if err := s.append(logRecord{Op: opPurge, Code: code}); err != nil {
+			log.Printf("⚠️  Erro ao remover link expirado %s: %v", code, err)
+			break
+		}
+		s.mem.purge(code)
+		removed++
+	}
+	return removed
+}
+
+// StartSweeper remove periodicamente os links expirados há mais de
+// retention até que a função retornada seja chamada
+func (s *FileStore) StartSweeper(interval, retention time.Duration) (stop func()) {
+	return startSweeper(func(now time.Time) int { return s.PurgeExpired(now, retention) }, interval)
+}
+
+func (s *FileStore) SaveAccount(account *models.Account) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.maybeCompact()
+
+	if err := s.append(logRecord{Op: opAccount, Account: account}); err != nil {
+		return err
+	}
+	return s.mem.SaveAccount(account)
+}
+
+func (s *FileStore) FindAccountByID(id string) (*models.Account, error) {
+	return s.mem.FindAccountByID(id)
+}
+
+func (s *FileStore) FindAccountByKeyHash(hash string) (*models.Account, error) {
+	return s.mem.FindAccountByKeyHash(hash)
+}
+
+func (s *FileStore) SaveBlockedDomain(domain *models.BlockedDomain) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.maybeCompact()
+
+	if err := s.append(logRecord{Op: opBlock, Blocked: domain}); err != nil {
+		return err
+	}
+	return s.mem.SaveBlockedDomain(domain)
+}
+
+func (s *FileStore) DeleteBlockedDomain(pattern string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.maybeCompact()
+
+	if !s.mem.hasBlockedDomain(pattern) {
+		return ErrPatternNotFound
+	}
+
+	if err := s.append(logRecord{Op: opUnblock, Code: pattern}); err != nil {
+		return err
+	}
+	return s.mem.DeleteBlockedDomain(pattern)
+}
+
+func (s *FileStore) GetBlockedDomains() []*models.BlockedDomain {
+	return s.mem.GetBlockedDomains()
+}
+
+// Count retorna o número de links armazenados
+func (s *FileStore) Count() int {
+	return s.mem.Count()
+}
+
+// LastSequence retorna o maior número de sequência já gravado, inclusive
+// de links removidos
+func (s *FileStore) LastSequence() uint64 {
+	return s.mem.LastSequence()
+}
+
+// Ping verifica se o log continua aberto e se o arquivo no disco ainda é o
+// mesmo (não foi removido ou substituído)
+func (s *FileStore) Ping() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	open, err := s.file.Stat()
+	if err != nil {
+		return fmt.Errorf("log inacessível: %w", err)
+	}
+	onDisk, err := os.Stat(s.path)
+	if err != nil {
+		return fmt.Errorf("log inacessível: %w", err)
+	}
+	if !os.SameFile(open, onDisk) {
+		return fmt.Errorf("log %s foi substituído", s.path)
+	}
+	return nil
+}
+
+// Close grava os dados pendentes em disco e fecha o log
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.file.Sync(); err != nil {
+		s.file.Close()
+		return err
+	}
+	return s.file.Close()
+}
+
+// append grava um registro no log; deve ser chamado com s.mu travado
+func (s *FileStore) append(rec logRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	if err := s.write(append(data, '\n')); err != nil {
+		return err
+	}
+
+	s.records++
+	return nil
+}
+
+// write grava as linhas no log e só retorna depois de chegarem ao disco,
+// para que uma queda não perca alterações já confirmadas
+func (s *FileStore) write(data []byte) error {
+	if _, err := s.file.Write(data); err != nil {
+		return fmt.Errorf("erro ao gravar log: %w", err)
+	}
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("erro ao gravar log: %w", err)
+	}
+	return nil
+}
+
+// maybeCompact compacta o log quando há registros obsoletos demais. Deve ser
+// chamado com s.mu travado e depois de aplicar a alteração em memória, senão
+// o snapshot perderia o registro recém-gravado.
+func (s *FileStore) maybeCompact() {
+	if s.records >= compactMinRecords && s.records > compactRatio*s.liveRecords() {
+		if err := s.compact(); err != nil {
+			log.Printf("⚠️  Erro ao compactar %s: %v", s.path, err)
+		}
+	}
+}
+
+// replay reconstrói o estado em memória a partir do log
+func (s *FileStore) replay() error {
+	f, err := os.Open(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+
+		var rec logRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			// Provavelmente uma escrita interrompida; a compactação descarta a linha
+			log.Printf("⚠️  Registro inválido em %s:%d ignorado: %v", s.path, line, err)
+			continue
+		}
+
+		switch rec.Op {
+		case opSave:
+			if rec.URL != nil {
+				s.mem.Save(rec.URL)
+			}
+		case opClick:
+			if rec.Click != nil {
+				s.mem.RecordClick(rec.Click)
+			} else {
+				s.mem.incrementClicks(rec.Code)
+			}
+		case opEvent:
+			if rec.Click != nil {
+				s.mem.restoreClick(rec.Click)
+			}
+		case opAccount:
+			if rec.Account != nil {
+				s.mem.SaveAccount(rec.Account)
+			}
+		case opDelete:
+			s.mem.Delete(rec.Code)
+		case opPurge:
+			s.mem.purge(rec.Code)
+		case opBlock:
+			if rec.Blocked != nil {
+				s.mem.SaveBlockedDomain(rec.Blocked)
+			}
+		case opUnblock:
+			s.mem.DeleteBlockedDomain(rec.Code)
+		case opSequence:
+			s.mem.restoreSequence(rec.Sequence)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// liveRecords é o número de registros que um snapshot teria
+func (s *FileStore) liveRecords() int {
+	return s.mem.Count() + s.mem.eventCount() + s.mem.accountCount() + len(s.mem.GetBlockedDomains()) + s.mem.purgedCount()
+}
+
+// compact reescreve o log com um registro por URL e por clique vivos e o
+// reabre para escrita. Deve ser chamado com s.mu travado (ou antes do store
+// ser publicado).
+func (s *FileStore) compact() error {
+	tmpPath := s.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	records := make([]logRecord, 0, s.liveRecords()+1)
+	// Os links com os maiores números podem ter sido removidos
+	if sequence := s.mem.LastSequence(); sequence > 0 {
+		records = append(records, logRecord{Op: opSequence, Sequence: sequence})
+	}
+	for _, account := range s.mem.allAccounts() {
+		records = append(records, logRecord{Op: opAccount, Account: account})
+	}
+	for _, domain := range s.mem.GetBlockedDomains() {
+		records = append(records, logRecord{Op: opBlock, Blocked: domain})
+	}
+	for _, url := range s.mem.GetAll() {
+		records = append(records, logRecord{Op: opSave, URL: url})
+	}
+	for _, code := range s.mem.purgedCodes() {
+		records = append(records, logRecord{Op: opPurge, Code: code})
+	}
+	for _, click := range s.mem.allClicks() {
+		records = append(records, logRecord{Op: opEvent, Click: click})
+	}
+
+	for _, rec := range records {
+		if err := enc.Encode(rec); err != nil {
+			tmp.Close()
+			os.Remove(tmpPath)
+			return err
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return err
+	}
+	// A troca de arquivos só é durável depois de gravado o diretório
+	if err := syncDir(filepath.Dir(s.path)); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	if s.file != nil {
+		s.file.Close()
+	}
+	s.file = file
+	s.records = len(records)
+	return nil
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,257 @@
+package repository
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"url-shortener/internal/models"
+)
+
+func newTestURL(code string) *models.URL {
+	return &models.URL{
+		ID:          "id-" + code,
+		OriginalURL: "https://example.com/" + code,
+		ShortCode:   code,
+		CreatedAt:   time.Now(),
+	}
+}
+
+func openTestStore(t *testing.T, path string) *FileStore {
+	t.Helper()
+	s, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.Count(data, []byte("\n"))
+}
+
+func TestFileStoreIgnoresTruncatedTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "urls.log")
+
+	s := openTestStore(t, path)
+	if err := s.Save(newTestURL("abc123")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RecordClick(&models.ClickEvent{ShortCode: "abc123", Timestamp: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	// Escrita interrompida no meio de um registro, sem a quebra de linha
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"op":"save","url":{"short_code":"trunc`)
+	f.Close()
+
+	s = openTestStore(t, path)
+	url, err := s.FindByShortCode("abc123")
+	if err != nil {
+		t.Fatalf("link gravado antes da falha perdido: %v", err)
+	}
+	if url.Clicks != 1 {
+		t.Errorf("Clicks = %d, esperado 1", url.Clicks)
+	}
+	if _, err := s.FindByShortCode("trunc"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("registro truncado foi aplicado: %v", err)
+	}
+
+	// A linha truncada não pode corromper as gravações seguintes
+	if err := s.Save(newTestURL("after")); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	s = openTestStore(t, path)
+	for _, code := range []string{"abc123", "after"} {
+		if _, err := s.FindByShortCode(code); err != nil {
+			t.Errorf("%s perdido após reabrir: %v", code, err)
+		}
+	}
+}
+
+func TestFileStoreReplayAfterCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "urls.log")
+
+	s := openTestStore(t, path)
+	if err := s.Save(newTestURL("abc123")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Cada Update acrescenta um registro obsoleto até disparar a compactação
+	for i := range compactMinRecords + 10 {
+		_, err := s.Update("abc123", func(url *models.URL) error {
+			url.MaxClicks = i + 1
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if lines := countLines(t, path); lines >= compactMinRecords {
+		t.Fatalf("log com %d linhas, esperada compactação", lines)
+	}
+
+	clickAt := time.Now().Add(-time.Hour)
+	if err := s.RecordClicks([]*models.ClickEvent{
+		{ShortCode: "abc123", Timestamp: clickAt},
+		{ShortCode: "abc123", Timestamp: clickAt.Add(time.Minute)},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	s = openTestStore(t, path)
+	url, err := s.FindByShortCode("abc123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url.MaxClicks != compactMinRecords+10 {
+		t.Errorf("MaxClicks = %d, esperado o último Update (%d)", url.MaxClicks, compactMinRecords+10)
+	}
+	if url.Clicks != 2 {
+		t.Errorf("Clicks = %d, esperado 2", url.Clicks)
+	}
+	if events := s.GetClicks("abc123", clickAt, time.Now()); len(events) != 2 {
+		t.Errorf("%d eventos de clique, esperados 2", len(events))
+	}
+
+	// Reabrir compacta de novo: o snapshot não pode contar os cliques duas vezes
+	s.Close()
+	s = openTestStore(t, path)
+	if url, _ := s.FindByShortCode("abc123"); url.Clicks != 2 {
+		t.Errorf("Clicks = %d após o segundo snapshot, esperado 2", url.Clicks)
+	}
+}
+
+func TestFileStoreDeleteSurvivesReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "urls.log")
+
+	s := openTestStore(t, path)
+	for _, code := range []string{"keep", "gone", "again"} {
+		if err := s.Save(newTestURL(code)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.RecordClick(&models.ClickEvent{ShortCode: "gone", Timestamp: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete("gone"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete("again"); err != nil {
+		t.Fatal(err)
+	}
+	// Um save depois da remoção vale
+	if err := s.Save(newTestURL("again")); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	// Primeiro o replay do log com as remoções, depois o do snapshot
+	for range 2 {
+		s = openTestStore(t, path)
+		if _, err := s.FindByShortCode("gone"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("link removido voltou: %v", err)
+		}
+		if events := s.GetClicks("gone", time.Time{}, time.Now().Add(time.Hour)); len(events) != 0 {
+			t.Errorf("%d cliques do link removido restaurados", len(events))
+		}
+		for _, code := range []string{"keep", "again"} {
+			if _, err := s.FindByShortCode(code); err != nil {
+				t.Errorf("%s perdido: %v", code, err)
+			}
+		}
+		s.Close()
+	}
+}
+
+func TestFileStorePurgeKeepsKeyReserved(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "urls.log")
+	now := time.Now()
+	retention := 24 * time.Hour
+
+	s := openTestStore(t, path)
+	old := newTestURL("old")
+	oldExpiry := now.Add(-2 * retention)
+	old.ExpiresAt = &oldExpiry
+	recent := newTestURL("recent")
+	recentExpiry := now.Add(-time.Hour)
+	recent.ExpiresAt = &recentExpiry
+	for _, url := range []*models.URL{old, recent, newTestURL("active")} {
+		if err := s.Save(url); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if removed := s.PurgeExpired(now, retention); removed != 1 {
+		t.Fatalf("PurgeExpired removeu %d links, esperado 1", removed)
+	}
+	s.Close()
+
+	s = openTestStore(t, path)
+	if _, err := s.FindByShortCode("old"); !errors.Is(err, ErrExpired) {
+		t.Errorf("link removido: err = %v, esperado ErrExpired", err)
+	}
+	if _, err := s.Update("old", func(*models.URL) error { return nil }); !errors.Is(err, ErrExpired) {
+		t.Errorf("Update no link removido: err = %v, esperado ErrExpired", err)
+	}
+	// Ainda dentro da retenção: dados e estatísticas continuam disponíveis
+	for _, code := range []string{"recent", "active"} {
+		if _, err := s.FindByShortCode(code); err != nil {
+			t.Errorf("%s não deveria ter sido removido: %v", code, err)
+		}
+	}
+	if _, err := s.FindByShortCode("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("chave inexistente: err = %v, esperado ErrNotFound", err)
+	}
+}
+
+func TestFileStoreKeepsSequenceOfRemovedLinks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "urls.log")
+	now := time.Now()
+
+	s := openTestStore(t, path)
+	for seq, code := range map[uint64]string{1: "first", 2: "deleted", 3: "expired"} {
+		url := newTestURL(code)
+		url.Sequence = seq
+		if code == "expired" {
+			expiredAt := now.Add(-48 * time.Hour)
+			url.ExpiresAt = &expiredAt
+		}
+		if err := s.Save(url); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Delete("deleted"); err != nil {
+		t.Fatal(err)
+	}
+	s.PurgeExpired(now, 24*time.Hour)
+	if got := s.LastSequence(); got != 3 {
+		t.Fatalf("LastSequence = %d, esperado 3", got)
+	}
+	s.Close()
+
+	// Primeiro o replay do log, depois o do snapshot sem os links removidos
+	for range 2 {
+		s = openTestStore(t, path)
+		if got := s.LastSequence(); got != 3 {
+			t.Errorf("LastSequence = %d após reabrir, esperado 3", got)
+		}
+		s.Close()
+	}
+}
@@ -1,19 +1,38 @@
 package repository
 
 import (
-	"errors"
+	"sort"
 	"sync"
+	"time"
 	"url-shortener/internal/models"
 )
 
 type MemoryStore struct {
-	mu   sync.RWMutex
-	urls map[string]*models.URL // shortCode -> URL
+	mu     sync.RWMutex
+	urls   map[string]*models.URL          // chave do link (models.LinkKey) -> URL
+	clicks map[string][]*models.ClickEvent // chave do link -> cliques em ordem cronológica
+	events int                             // total de eventos de clique armazenados
+
+	destinations map[string]map[string]bool // dono + destino normalizado -> chaves dos links
+	purged       map[string]bool            // chaves de links expirados removidos, ainda reservadas
+	sequence     uint64                     // maior URL.Sequence já gravado, mesmo de links removidos
+
+	accounts map[string]*models.Account // accountID -> conta
+	keyIndex map[string]string          // hash da chave de API -> accountID
+
+	blocked map[string]*models.BlockedDomain // padrão -> domínio bloqueado
 }
 
 func NewMemoryStore() *MemoryStore {
 	return &MemoryStore{
-		urls: make(map[string]*models.URL),
+		urls:     make(map[string]*models.URL),
+		clicks:   make(map[string][]*models.ClickEvent),
+		accounts: make(map[string]*models.Account),
+		keyIndex: make(map[string]string),
+		blocked:  make(map[string]*models.BlockedDomain),
+
+		destinations: make(map[string]map[string]bool),
+		purged:       make(map[string]bool),
 	}
 }
 
@@ -21,7 +40,8 @@ func (s *MemoryStore) Save(url *models.URL) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	
-	s.urls[url.ShortCode] = url
+	// O chamador continua com o seu ponteiro; o store guarda uma cópia
+	s.putLocked(copyURL(url))
 	return nil
 }
 
@@ -31,33 +51,439 @@ func (s *MemoryStore) FindByShortCode(code string) (*models.URL, error) {
 	
 	url, exists := s.urls[code]
 	if !exists {
-		return nil, errors.New("URL não encontrada")
+		return nil, s.missingLocked(code)
 	}
 	
-	return url, nil
+	return copyURL(url), nil
 }
 
-func (s *MemoryStore) IncrementClicks(code string) error {
+// RecordClick incrementa o contador do link e armazena o evento de clique
+func (s *MemoryStore) RecordClick(event *models.ClickEvent) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	
-	url, exists := s.urls[code]
+
+	url, exists := s.urls[event.LinkKey()]
 	if !exists {
-		return errors.New("URL não encontrada")
+		return ErrNotFound
 	}
-	
+
 	url.Clicks++
+	s.addEventLocked(event)
 	return nil
 }
 
+// RecordClicks registra um lote de cliques; cliques de links removidos são
+// descartados
+func (s *MemoryStore) RecordClicks(events []*models.ClickEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, event := range events {
+		if url, exists := s.urls[event.LinkKey()]; exists {
+			url.Clicks++
+			s.addEventLocked(event)
+		}
+	}
+	return nil
+}
+
+// GetClicks retorna os cliques do link no intervalo [from, to)
+func (s *MemoryStore) GetClicks(code string, from, to time.Time) []*models.ClickEvent {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	events := s.clicks[code]
+	start := sort.Search(len(events), func(i int) bool {
+		return !events[i].Timestamp.Before(from)
+	})
+
+	var result []*models.ClickEvent
+	for _, event := range events[start:] {
+		if !event.Timestamp.Before(to) {
+			break
+		}
+		result = append(result, event)
+	}
+	return result
+}
+
+// restoreClick adiciona um evento sem alterar o contador (usado ao recarregar snapshots)
+func (s *MemoryStore) restoreClick(event *models.ClickEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.urls[event.LinkKey()]; exists {
+		s.addEventLocked(event)
+	}
+}
+
+// incrementClicks incrementa apenas o contador (registros antigos sem evento)
+func (s *MemoryStore) incrementClicks(code string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if url, exists := s.urls[code]; exists {
+		url.Clicks++
+	}
+}
+
+// addEventLocked insere o evento mantendo a ordem cronológica; deve ser
+// chamado com s.mu travado
+func (s *MemoryStore) addEventLocked(event *models.ClickEvent) {
+	key := event.LinkKey()
+	events := s.clicks[key]
+	i := len(events)
+	for i > 0 && events[i-1].Timestamp.After(event.Timestamp) {
+		i--
+	}
+	events = append(events, nil)
+	copy(events[i+1:], events[i:])
+	events[i] = event
+
+	s.clicks[key] = events
+	s.events++
+}
+
+// putLocked grava o link e atualiza o índice de destinos; deve ser chamado
+// com s.mu travado
+func (s *MemoryStore) putLocked(url *models.URL) {
+	if previous, exists := s.urls[url.Key()]; exists {
+		s.unindexLocked(previous)
+	}
+	s.urls[url.Key()] = url
+	s.sequence = max(s.sequence, url.Sequence)
+
+	if url.DedupeKey == "" {
+		return
+	}
+	key := destinationKey(url.OwnerID, url.DedupeKey)
+	if s.destinations[key] == nil {
+		s.destinations[key] = make(map[string]bool)
+	}
+	s.destinations[key][url.Key()] = true
+}
+
+func (s *MemoryStore) unindexLocked(url *models.URL) {
+	if url.DedupeKey == "" {
+		return
+	}
+	key := destinationKey(url.OwnerID, url.DedupeKey)
+	delete(s.destinations[key], url.Key())
+	if len(s.destinations[key]) == 0 {
+		delete(s.destinations, key)
+	}
+}
+
+// deleteLocked remove o link e seus cliques; deve ser chamado com s.mu travado
+func (s *MemoryStore) deleteLocked(code string) {
+	if url, exists := s.urls[code]; exists {
+		s.unindexLocked(url)
+	}
+	delete(s.urls, code)
+	s.events -= len(s.clicks[code])
+	delete(s.clicks, code)
+}
+
+// allClicks retorna todos os eventos armazenados, agrupados por link
+func (s *MemoryStore) allClicks() []*models.ClickEvent {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]*models.ClickEvent, 0, s.events)
+	for _, events := range s.clicks {
+		result = append(result, events...)
+	}
+	return result
+}
+
+func (s *MemoryStore) eventCount() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.events
+}
+
 func (s *MemoryStore) GetAll() []*models.URL {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	
 	result := make([]*models.URL, 0, len(s.urls))
 	for _, url := range s.urls {
-		result = append(result, url)
+		result = append(result, copyURL(url))
 	}
 	
 	return result
 }
+
+// FindByDestination retorna os links do dono com o destino normalizado
+// informado, do mais antigo para o mais recente
+func (s *MemoryStore) FindByDestination(ownerID, dedupeKey string) []*models.URL {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	codes := s.destinations[destinationKey(ownerID, dedupeKey)]
+	result := make([]*models.URL, 0, len(codes))
+	for code := range codes {
+		result = append(result, copyURL(s.urls[code]))
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result
+}
+
+// copyURL copia o link para ser lido fora da trava. A cópia é rasa: os
+// campos de ponteiro e slices nunca são alterados no lugar (Update grava uma
+// cópia nova), só o contador de cliques.
+func copyURL(url *models.URL) *models.URL {
+	copied := *url
+	return &copied
+}
+
+func destinationKey(ownerID, dedupeKey string) string {
+	return ownerID + "\x00" + dedupeKey
+}
+
+func (s *MemoryStore) Count() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.urls)
+}
+
+func (s *MemoryStore) Update(code string, fn func(url *models.URL) error) (*models.URL, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, exists := s.urls[code]
+	if !exists {
+		return nil, s.missingLocked(code)
+	}
+
+	updated := *current
+	if err := fn(&updated); err != nil {
+		return nil, err
+	}
+
+	s.putLocked(&updated)
+	return copyURL(&updated), nil
+}
+
+func (s *MemoryStore) Delete(code string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.urls[code]; !exists {
+		return s.missingLocked(code)
+	}
+
+	s.deleteLocked(code)
+	return nil
+}
+
+// missingLocked é o erro de uma chave sem link: ErrExpired se o link expirou
+// e foi removido, senão ErrNotFound
+func (s *MemoryStore) missingLocked(code string) error {
+	if s.purged[code] {
+		return ErrExpired
+	}
+	return ErrNotFound
+}
+
+// PurgeExpired remove os links expirados há mais de retention, mantendo as
+// chaves reservadas, e retorna quantos foram removidos
+func (s *MemoryStore) PurgeExpired(now time.Time, retention time.Duration) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	for code, url := range s.urls {
+		if s.purgeableLocked(url, now, retention) {
+			s.purgeLocked(code)
+			removed++
+		}
+	}
+	return removed
+}
+
+// StartSweeper remove periodicamente os links expirados há mais de
+// retention até que a função retornada seja chamada
+func (s *MemoryStore) StartSweeper(interval, retention time.Duration) (stop func()) {
+	return startSweeper(func(now time.Time) int { return s.PurgeExpired(now, retention) }, interval)
+}
+
+// purgeableCodes lista as chaves dos links expirados há mais de retention
+func (s *MemoryStore) purgeableCodes(now time.Time, retention time.Duration) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var codes []string
+	for code, url := range s.urls {
+		if s.purgeableLocked(url, now, retention) {
+			codes = append(codes, code)
+		}
+	}
+	return codes
+}
+
+// purgeableLocked indica se o link expirou há mais de retention. Links que
+// esgotaram os cliques expiram no último clique; deve ser chamado com s.mu
+// travado.
+func (s *MemoryStore) purgeableLocked(url *models.URL, now time.Time, retention time.Duration) bool {
+	if !url.IsExpired(now) {
+		return false
+	}
+
+	expiredAt := url.CreatedAt
+	if url.ExpiresAt != nil && !now.Before(*url.ExpiresAt) {
+		expiredAt = *url.ExpiresAt
+	} else if events := s.clicks[url.Key()]; len(events) > 0 {
+		expiredAt = events[len(events)-1].Timestamp
+	}
+	return !now.Before(expiredAt.Add(retention))
+}
+
+// purge remove o link e reserva a chave
+func (s *MemoryStore) purge(code string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.purgeLocked(code)
+}
+
+func (s *MemoryStore) purgeLocked(code string) {
+	s.deleteLocked(code)
+	s.purged[code] = true
+}
+
+// LastSequence retorna o maior número de sequência já gravado
+func (s *MemoryStore) LastSequence() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.sequence
+}
+
+// restoreSequence recupera a marca de sequência de links já removidos
+// (usado ao recarregar snapshots)
+func (s *MemoryStore) restoreSequence(sequence uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sequence = max(s.sequence, sequence)
+}
+
+func (s *MemoryStore) purgedCount() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.purged)
+}
+
+// purgedCodes lista as chaves reservadas de links removidos
+func (s *MemoryStore) purgedCodes() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	codes := make([]string, 0, len(s.purged))
+	for code := range s.purged {
+		codes = append(codes, code)
+	}
+	return codes
+}
+
+func (s *MemoryStore) SaveAccount(account *models.Account) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if previous, exists := s.accounts[account.ID]; exists {
+		for _, key := range previous.APIKeys {
+			delete(s.keyIndex, key.Hash)
+		}
+	}
+
+	s.accounts[account.ID] = account
+	for _, key := range account.APIKeys {
+		s.keyIndex[key.Hash] = account.ID
+	}
+	return nil
+}
+
+func (s *MemoryStore) FindAccountByID(id string) (*models.Account, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	account, exists := s.accounts[id]
+	if !exists {
+		return nil, ErrAccountNotFound
+	}
+	return account, nil
+}
+
+func (s *MemoryStore) FindAccountByKeyHash(hash string) (*models.Account, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	id, exists := s.keyIndex[hash]
+	if !exists {
+		return nil, ErrAccountNotFound
+	}
+	return s.accounts[id], nil
+}
+
+func (s *MemoryStore) allAccounts() []*models.Account {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]*models.Account, 0, len(s.accounts))
+	for _, account := range s.accounts {
+		result = append(result, account)
+	}
+	return result
+}
+
+func (s *MemoryStore) accountCount() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.accounts)
+}
+
+func (s *MemoryStore) SaveBlockedDomain(domain *models.BlockedDomain) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.blocked[domain.Pattern] = domain
+	return nil
+}
+
+func (s *MemoryStore) DeleteBlockedDomain(pattern string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.blocked[pattern]; !exists {
+		return ErrPatternNotFound
+	}
+	delete(s.blocked, pattern)
+	return nil
+}
+
+func (s *MemoryStore) GetBlockedDomains() []*models.BlockedDomain {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]*models.BlockedDomain, 0, len(s.blocked))
+	for _, domain := range s.blocked {
+		result = append(result, domain)
+	}
+	return result
+}
+
+func (s *MemoryStore) hasBlockedDomain(pattern string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, exists := s.blocked[pattern]
+	return exists
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"sync"
+	"testing"
+	"time"
+	"url-shortener/internal/models"
+)
+
+// Executado com -race: leituras dos links retornados não podem concorrer
+// com o contador de cliques
+func TestMemoryStoreReadsDoNotRaceWithClicks(t *testing.T) {
+	s := NewMemoryStore()
+	url := newTestURL("abc123")
+	url.MaxClicks = 1000
+	if err := s.Save(url); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for range 200 {
+			s.RecordClick(&models.ClickEvent{ShortCode: "abc123", Timestamp: time.Now()})
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for range 200 {
+			if found, err := s.FindByShortCode("abc123"); err == nil {
+				found.IsExpired(time.Now())
+			}
+			for _, link := range s.GetAll() {
+				_ = link.Clicks
+			}
+		}
+	}()
+	// O ponteiro passado a Save também não é o que o store altera
+	for range 200 {
+		_ = url.Clicks
+	}
+	wg.Wait()
+
+	found, err := s.FindByShortCode("abc123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.Clicks != 200 {
+		t.Errorf("Clicks = %d, esperado 200", found.Clicks)
+	}
+	if url.Clicks != 0 {
+		t.Errorf("link do chamador alterado: Clicks = %d", url.Clicks)
+	}
+}
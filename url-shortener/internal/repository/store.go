@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+	"url-shortener/internal/models"
+)
+
+var (
+	ErrNotFound        = errors.New("URL não encontrada")
+	ErrExpired         = errors.New("link expirado e removido")
+	ErrAccountNotFound = errors.New("conta não encontrada")
+	ErrPatternNotFound = errors.New("padrão não está bloqueado")
+)
+
+// Store é o contrato de persistência usado pelo URLService. Os parâmetros
+// code são a chave do link (models.LinkKey): o código no domínio principal e
+// "domínio/código" nos domínios adicionais. Os links retornados são cópias,
+// que podem ser lidas sem travas enquanto os cliques são gravados.
+type Store interface {
+	Save(url *models.URL) error
+	// Update aplica fn sobre uma cópia do link e a grava no lugar do original
+	Update(code string, fn func(url *models.URL) error) (*models.URL, error)
+	Delete(code string) error
+	FindByShortCode(code string) (*models.URL, error)
+	RecordClick(event *models.ClickEvent) error
+	GetClicks(code string, from, to time.Time) []*models.ClickEvent
+	GetAll() []*models.URL
+}
+
+// AccountStore persiste as contas e o índice de chaves de API
+type AccountStore interface {
+	SaveAccount(account *models.Account) error
+	FindAccountByID(id string) (*models.Account, error)
+	FindAccountByKeyHash(hash string) (*models.Account, error)
+}
+
+// BlocklistStore persiste a lista de domínios bloqueados
+type BlocklistStore interface {
+	SaveBlockedDomain(domain *models.BlockedDomain) error
+	DeleteBlockedDomain(pattern string) error
+	GetBlockedDomains() []*models.BlockedDomain
+}
+
+// DestinationIndex é implementado pelos stores que mantêm o índice reverso
+// de destinos normalizados (URL.DedupeKey) usado na deduplicação
+type DestinationIndex interface {
+	FindByDestination(ownerID, dedupeKey string) []*models.URL
+}
+
+// BatchClickRecorder é implementado pelos stores que gravam vários cliques
+// de uma vez, usado pelo pipeline de cliques
+type BatchClickRecorder interface {
+	RecordClicks(events []*models.ClickEvent) error
+}
+
+// HealthChecker é implementado pelos stores que dependem de recursos
+// externos; Ping retorna erro quando o backend não pode atender
+type HealthChecker interface {
+	Ping() error
+}
+
+// Sweeper é implementado pelos stores que removem links expirados em
+// segundo plano. Links expirados há mais de retention perdem os dados e os
+// cliques, mas a chave continua reservada: FindByShortCode passa a retornar
+// ErrExpired e o código nunca é reutilizado.
+type Sweeper interface {
+	StartSweeper(interval, retention time.Duration) (stop func())
+}
+
+// SequenceTracker é implementado pelos stores que guardam o maior
+// URL.Sequence já gravado. O valor não diminui quando links são removidos,
+// para que o gerador sequencial nunca reutilize um número.
+type SequenceTracker interface {
+	LastSequence() uint64
+}
+
+var (
+	_ Store   = (*MemoryStore)(nil)
+	_ Store   = (*FileStore)(nil)
+	_ Sweeper = (*MemoryStore)(nil)
+	_ Sweeper = (*FileStore)(nil)
+
+	_ DestinationIndex = (*MemoryStore)(nil)
+	_ DestinationIndex = (*FileStore)(nil)
+
+	_ BatchClickRecorder = (*MemoryStore)(nil)
+	_ BatchClickRecorder = (*FileStore)(nil)
+
+	_ HealthChecker = (*FileStore)(nil)
+
+	_ SequenceTracker = (*MemoryStore)(nil)
+	_ SequenceTracker = (*FileStore)(nil)
+
+	_ AccountStore = (*MemoryStore)(nil)
+	_ AccountStore = (*FileStore)(nil)
+
+	_ BlocklistStore = (*MemoryStore)(nil)
+	_ BlocklistStore = (*FileStore)(nil)
+)
+
+func startSweeper(purge func(now time.Time) int, interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case now := <-ticker.C:
+				if removed := purge(now); removed > 0 {
+					log.Printf("🧹 %d link(s) expirado(s) removido(s)", removed)
+				}
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+	"url-shortener/internal/models"
+	"url-shortener/internal/repository"
+)
+
+const (
+	apiKeyPrefix       = "usk_"
+	apiKeyVisibleChars = 8 // caracteres guardados em claro para identificar a chave
+	maxAccountName     = 100
+)
+
+var (
+	ErrInvalidAccountName = errors.New("nome da conta é obrigatório (máximo de 100 caracteres)")
+	ErrInvalidAPIKey      = errors.New("chave de API inválida")
+)
+
+type AccountService struct {
+	store repository.AccountStore
+	mu    sync.Mutex // serializa alterações nas chaves de uma conta
+}
+
+func NewAccountService(store repository.AccountStore) *AccountService {
+	return &AccountService{store: store}
+}
+
+// CreateAccount cria a conta com uma chave de API inicial
+func (s *AccountService) CreateAccount(name string) (*models.CreateAPIKeyResponse, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxAccountName {
+		return nil, ErrInvalidAccountName
+	}
+
+	key, rawKey := newAPIKey()
+	account := &models.Account{
+		ID:        newID(12),
+		Name:      name,
+		CreatedAt: time.Now(),
+		APIKeys:   []models.APIKey{key},
+	}
+
+	if err := s.store.SaveAccount(account); err != nil {
+		return nil, err
+	}
+
+	return &models.CreateAPIKeyResponse{
+		Account: account.ToResponse(),
+		KeyID:   key.ID,
+		APIKey:  rawKey,
+	}, nil
+}
+
+// CreateAPIKey adiciona uma nova chave à conta
+func (s *AccountService) CreateAPIKey(accountID string) (*models.CreateAPIKeyResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, err := s.store.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	key, rawKey := newAPIKey()
+	account := *current
+	account.APIKeys = append(append([]models.APIKey{}, current.APIKeys...), key)
+
+	if err := s.store.SaveAccount(&account); err != nil {
+		return nil, err
+	}
+
+	return &models.CreateAPIKeyResponse{
+		Account: account.ToResponse(),
+		KeyID:   key.ID,
+		APIKey:  rawKey,
+	}, nil
+}
+
+// Authenticate retorna a conta dona da chave de API
+func (s *AccountService) Authenticate(rawKey string) (*models.Account, error) {
+	if !strings.HasPrefix(rawKey, apiKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	account, err := s.store.FindAccountByKeyHash(hashAPIKey(rawKey))
+	if err != nil {
+		return nil, ErrInvalidAPIKey
+	}
+	return account, nil
+}
+
+func newAPIKey() (models.APIKey, string) {
+	b := make([]byte, 32)
+	rand.Read(b)
+	rawKey := apiKeyPrefix + hex.EncodeToString(b)
+
+	return models.APIKey{
+		ID:        newID(8),
+		Prefix:    rawKey[:len(apiKeyPrefix)+apiKeyVisibleChars],
+		Hash:      hashAPIKey(rawKey),
+		CreatedAt: time.Now(),
+	}, rawKey
+}
+
+// hashAPIKey usa SHA-256: as chaves têm 256 bits aleatórios, então não é
+// necessário um hash lento como o de senhas
+func hashAPIKey(rawKey string) string {
+	sum := sha256.Sum256([]byte(rawKey))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"errors"
+	"path"
+	"sort"
+	"strings"
+	"time"
+	"url-shortener/internal/models"
+	"url-shortener/internal/repository"
+)
+
+var ErrInvalidPattern = errors.New("padrão inválido: use um host como \"evil.com\" ou \"*.evil.com\"")
+
+// BlocklistService administra os domínios bloqueados. Os padrões usam
+// curingas no estilo glob: "*.evil.com" bloqueia todos os subdomínios e
+// "evil.*" qualquer TLD; "*" casa com qualquer sequência, inclusive pontos.
+type BlocklistService struct {
+	store repository.BlocklistStore
+}
+
+func NewBlocklistService(store repository.BlocklistStore) *BlocklistService {
+	return &BlocklistService{store: store}
+}
+
+func (s *BlocklistService) Block(pattern, reason string) (*models.BlockedDomain, error) {
+	pattern = normalizeHost(pattern)
+	if pattern == "" || pattern == "*" || strings.ContainsAny(pattern, "/:@ ") {
+		return nil, ErrInvalidPattern
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, ErrInvalidPattern
+	}
+
+	domain := &models.BlockedDomain{
+		Pattern:   pattern,
+		Reason:    strings.TrimSpace(reason),
+		CreatedAt: time.Now(),
+	}
+	if err := s.store.SaveBlockedDomain(domain); err != nil {
+		return nil, err
+	}
+	return domain, nil
+}
+
+func (s *BlocklistService) Unblock(pattern string) error {
+	return s.store.DeleteBlockedDomain(normalizeHost(pattern))
+}
+
+func (s *BlocklistService) List() []*models.BlockedDomain {
+	domains := s.store.GetBlockedDomains()
+	sort.Slice(domains, func(i, j int) bool {
+		return domains[i].Pattern < domains[j].Pattern
+	})
+	return domains
+}
+
+// Matches indica se o host casa com algum padrão bloqueado
+func (s *BlocklistService) Matches(host string) bool {
+	host = normalizeHost(host)
+	for _, domain := range s.store.GetBlockedDomains() {
+		if matched, _ := path.Match(domain.Pattern, host); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+	"url-shortener/internal/models"
+	"url-shortener/internal/repository"
+)
+
+const (
+	defaultFlushInterval = time.Second
+	defaultClickBuffer   = 10000
+	maxClickBatch        = 500
+)
+
+// ClickRecorder acumula os cliques em um buffer e os grava em lotes, a cada
+// flushInterval ou quando o lote enche. Com o buffer cheio Record bloqueia
+// em vez de descartar cliques; Close grava tudo o que estiver pendente.
+type ClickRecorder struct {
+	store         repository.Store
+	events        chan *models.ClickEvent
+	flushInterval time.Duration
+	pending       atomic.Int64 // enfileirados e ainda não gravados
+
+	mu     sync.RWMutex // protege closed contra envios no canal fechado
+	closed bool
+	done   chan struct{}
+}
+
+// NewClickRecorder inicia o pipeline; valores <= 0 usam os padrões (1s e
+// 10000 cliques)
+func NewClickRecorder(store repository.Store, flushInterval time.Duration, bufferSize int) *ClickRecorder {
+	if flushInterval <= 0 {
+		flushInterval = defaultFlushInterval
+	}
+	if bufferSize <= 0 {
+		bufferSize = defaultClickBuffer
+	}
+
+	r := &ClickRecorder{
+		store:         store,
+		events:        make(chan *models.ClickEvent, bufferSize),
+		flushInterval: flushInterval,
+		done:          make(chan struct{}),
+	}
+	go r.run()
+	return r
+}
+
+// Record enfileira o clique; depois de Close grava diretamente no store
+func (r *ClickRecorder) Record(event *models.ClickEvent) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.closed {
+		r.write([]*models.ClickEvent{event})
+		return
+	}
+	r.pending.Add(1)
+	r.events <- event
+}
+
+// Pending retorna quantos cliques aguardam gravação
+func (r *ClickRecorder) Pending() int {
+	return int(r.pending.Load())
+}
+
+// Close para de aceitar cliques no buffer e espera a gravação dos pendentes
+// ou o fim do contexto
+func (r *ClickRecorder) Close(ctx context.Context) error {
+	r.mu.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.events)
+	}
+	r.mu.Unlock()
+
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *ClickRecorder) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*models.ClickEvent, 0, maxClickBatch)
+	flush := func() {
+		if len(batch) > 0 {
+			r.write(batch)
+			r.pending.Add(-int64(len(batch)))
+			batch = make([]*models.ClickEvent, 0, maxClickBatch)
+		}
+	}
+
+	for {
+		select {
+		case event, ok := <-r.events:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, event)
+			if len(batch) >= maxClickBatch {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+func (r *ClickRecorder) write(events []*models.ClickEvent) {
+	if batcher, ok := r.store.(repository.BatchClickRecorder); ok {
+		if err := batcher.RecordClicks(events); err != nil {
+			log.Printf("⚠️  Erro ao gravar %d clique(s): %v", len(events), err)
+		}
+		return
+	}
+
+	for _, event := range events {
+		if err := r.store.RecordClick(event); err != nil && !errors.Is(err, repository.ErrNotFound) && !errors.Is(err, repository.ErrExpired) {
+			log.Printf("⚠️  Erro ao gravar clique de %s: %v", event.LinkKey(), err)
+		}
+	}
+}
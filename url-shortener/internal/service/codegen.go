@@ -0,0 +1,305 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/big"
+	"strings"
+	"sync"
+	"url-shortener/internal/models"
+	"url-shortener/internal/repository"
+)
+
+const (
+	StrategyRandom  = "random"
+	StrategyCounter = "counter"
+
+	// DefaultCodeAlphabet não tem os caracteres que se confundem na
+	// leitura: 0/O/o, 1/l/I
+	DefaultCodeAlphabet = "23456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnpqrstuvwxyz"
+	idAlphabet          = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+	// Rodadas da rede de Feistel do CounterGenerator
+	feistelRounds = 8
+
+	// Tentativas de código livre antes de desistir; com o espaço quase cheio
+	// o laço de colisões deixaria a criação cada vez mais lenta
+	maxCodeAttempts = 10
+)
+
+var (
+	ErrKeyspaceExhausted = errors.New("não há códigos livres com esse tamanho; use um code_length maior")
+	ErrUnknownStrategy   = errors.New("estratégia de código desconhecida: use random ou counter")
+)
+
+// CodeGenerator gera os candidatos a código dos links sem alias. Candidatos
+// já em uso ou reservados são descartados pelo URLService.
+type CodeGenerator interface {
+	// Next retorna um código com length caracteres e, nos geradores
+	// sequenciais, o número de sequência usado (zero nos demais)
+	Next(length int) (code string, seq uint64, err error)
+	Strategy() string
+	Alphabet() string
+}
+
+// sequenceGenerator é implementado pelos geradores que dependem de um
+// contador, retomado a partir dos links salvos na inicialização
+type sequenceGenerator interface {
+	Resume(last uint64)
+	Issued() uint64
+}
+
+// NewCodeGenerator cria o gerador da estratégia configurada; salt só é
+// usado pela estratégia counter
+func NewCodeGenerator(strategy, alphabet, salt string) (CodeGenerator, error) {
+	if alphabet == "" {
+		alphabet = DefaultCodeAlphabet
+	}
+	switch strategy {
+	case "", StrategyRandom:
+		return NewRandomGenerator(alphabet), nil
+	case StrategyCounter:
+		return NewCounterGenerator(alphabet, salt), nil
+	default:
+		return nil, ErrUnknownStrategy
+	}
+}
+
+// RandomGenerator sorteia cada caractere do alfabeto de forma uniforme
+type RandomGenerator struct {
+	alphabet string
+}
+
+func NewRandomGenerator(alphabet string) *RandomGenerator {
+	return &RandomGenerator{alphabet: alphabet}
+}
+
+func (g *RandomGenerator) Next(length int) (string, uint64, error) {
+	return randomCode(length, g.alphabet), 0, nil
+}
+
+func (g *RandomGenerator) Strategy() string { return StrategyRandom }
+func (g *RandomGenerator) Alphabet() string { return g.alphabet }
+
+// CounterGenerator transforma um contador em códigos de aparência
+// aleatória: o número passa por uma permutação do espaço de códigos
+// (alfabeto^tamanho) chaveada pelo salt, uma rede de Feistel com HMAC-SHA256,
+// e é escrito com o alfabeto embaralhado pelo mesmo salt. Não há colisões até
+// o espaço acabar e códigos consecutivos não guardam relação entre si, mas só
+// enquanto o salt for secreto: sem salt, ou conhecendo-o, qualquer um
+// enumera a sequência.
+type CounterGenerator struct {
+	alphabet string
+	key      []byte
+
+	mu   sync.Mutex
+	next uint64
+}
+
+func NewCounterGenerator(alphabet, salt string) *CounterGenerator {
+	return &CounterGenerator{
+		alphabet: shuffleAlphabet(alphabet, fnvHash(salt)),
+		key:      []byte(salt),
+		next:     1,
+	}
+}
+
+func (g *CounterGenerator) Next(length int) (string, uint64, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	base := big.NewInt(int64(len(g.alphabet)))
+	space := new(big.Int).Exp(base, big.NewInt(int64(length)), nil)
+
+	seq := g.next
+	n := new(big.Int).SetUint64(seq)
+	if n.Cmp(space) >= 0 {
+		return "", 0, ErrKeyspaceExhausted
+	}
+	g.next++
+
+	x := g.permute(n, base, length)
+	code := make([]byte, length)
+	digit := new(big.Int)
+	for i := length - 1; i >= 0; i-- {
+		x.DivMod(x, base, digit)
+		code[i] = g.alphabet[digit.Int64()]
+	}
+	return string(code), seq, nil
+}
+
+// permute embaralha n no espaço base^length. O número é dividido em duas
+// metades de dígitos (left < base^h e right < base^(length-h)) e cada rodada
+// soma à metade da vez, módulo o seu tamanho, um hash da outra; cada rodada
+// é reversível, então o todo é uma bijeção do espaço.
+func (g *CounterGenerator) permute(n, base *big.Int, length int) *big.Int {
+	h := length / 2
+	leftSize := new(big.Int).Exp(base, big.NewInt(int64(h)), nil)
+	rightSize := new(big.Int).Exp(base, big.NewInt(int64(length-h)), nil)
+
+	left, right := new(big.Int).DivMod(n, rightSize, new(big.Int))
+	for round := range feistelRounds {
+		if round%2 == 0 {
+			left.Add(left, g.roundHash(round, length, right))
+			left.Mod(left, leftSize)
+		} else {
+			right.Add(right, g.roundHash(round, length, left))
+			right.Mod(right, rightSize)
+		}
+	}
+	return left.Mul(left, rightSize).Add(left, right)
+}
+
+// roundHash é a função de rodada: HMAC-SHA256 do salt sobre a rodada, o
+// tamanho do código e a metade de entrada
+func (g *CounterGenerator) roundHash(round, length int, half *big.Int) *big.Int {
+	mac := hmac.New(sha256.New, g.key)
+	mac.Write([]byte{byte(round), byte(length)})
+	mac.Write(half.Bytes())
+	return new(big.Int).SetBytes(mac.Sum(nil))
+}
+
+// Resume continua a contagem depois da maior sequência já usada
+func (g *CounterGenerator) Resume(last uint64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if last >= g.next {
+		g.next = last + 1
+	}
+}
+
+// Issued retorna quantos números de sequência já foram usados
+func (g *CounterGenerator) Issued() uint64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.next - 1
+}
+
+func (g *CounterGenerator) Strategy() string { return StrategyCounter }
+func (g *CounterGenerator) Alphabet() string { return g.alphabet }
+
+// SetCodeGenerator troca a estratégia de geração dos códigos. Geradores
+// sequenciais retomam a contagem depois do maior número já gravado; stores
+// sem repository.SequenceTracker só conhecem os links ainda salvos.
+func (s *URLService) SetCodeGenerator(generator CodeGenerator) {
+	if seq, ok := generator.(sequenceGenerator); ok {
+		var last uint64
+		if tracker, ok := s.store.(repository.SequenceTracker); ok {
+			last = tracker.LastSequence()
+		} else {
+			for _, url := range s.store.GetAll() {
+				last = max(last, url.Sequence)
+			}
+		}
+		seq.Resume(last)
+	}
+	s.codes = generator
+}
+
+// nextCode gera um código livre no domínio; deve ser chamado com createMu
+// travado
+func (s *URLService) nextCode(domain string, length int) (string, uint64, error) {
+	for range maxCodeAttempts {
+		code, seq, err := s.codes.Next(length)
+		if err != nil {
+			return "", 0, err
+		}
+		if reservedWords[strings.ToLower(code)] {
+			continue
+		}
+		if !s.keyTaken(models.LinkKey(domain, code)) {
+			return code, seq, nil
+		}
+	}
+	return "", 0, ErrKeyspaceExhausted
+}
+
+// KeyspaceUsage informa quanto do espaço de códigos do tamanho padrão já
+// está ocupado, no domínio mais cheio
+func (s *URLService) KeyspaceUsage() *models.KeyspaceUsage {
+	alphabet := s.codes.Alphabet()
+	usage := &models.KeyspaceUsage{
+		Strategy:     s.codes.Strategy(),
+		AlphabetSize: len(alphabet),
+		Length:       s.codeLength,
+		Capacity:     math.Pow(float64(len(alphabet)), float64(s.codeLength)),
+	}
+
+	perDomain := make(map[string]int)
+	for _, url := range s.store.GetAll() {
+		if len(url.ShortCode) == s.codeLength && inAlphabet(url.ShortCode, alphabet) {
+			perDomain[url.Domain]++
+		}
+	}
+	for _, n := range perDomain {
+		usage.Used = max(usage.Used, uint64(n))
+	}
+
+	// No contador os números usados por links removidos não voltam
+	if seq, ok := s.codes.(sequenceGenerator); ok {
+		usage.Used = max(usage.Used, seq.Issued())
+	}
+
+	usage.Ratio = float64(usage.Used) / usage.Capacity
+	return usage
+}
+
+// randomCode sorteia length caracteres do alfabeto com distribuição uniforme
+func randomCode(length int, alphabet string) string {
+	// Descarta os bytes acima do maior múltiplo do tamanho do alfabeto para
+	// evitar viés do módulo
+	limit := 256 - 256%len(alphabet)
+	code := make([]byte, 0, length)
+	buf := make([]byte, length*2)
+
+	for len(code) < length {
+		rand.Read(buf)
+		for _, b := range buf {
+			if int(b) < limit && len(code) < length {
+				code = append(code, alphabet[int(b)%len(alphabet)])
+			}
+		}
+	}
+	return string(code)
+}
+
+// newID gera os identificadores internos de links e contas
+func newID(length int) string {
+	return randomCode(length, idAlphabet)
+}
+
+func inAlphabet(code, alphabet string) bool {
+	for _, c := range code {
+		if !strings.ContainsRune(alphabet, c) {
+			return false
+		}
+	}
+	return true
+}
+
+// shuffleAlphabet embaralha o alfabeto de forma determinística pelo salt
+// (Fisher-Yates com xorshift), para que o mesmo salt gere sempre os mesmos
+// códigos
+func shuffleAlphabet(alphabet string, seed uint64) string {
+	chars := []byte(alphabet)
+	state := seed | 1
+	for i := len(chars) - 1; i > 0; i-- {
+		state ^= state << 13
+		state ^= state >> 7
+		state ^= state << 17
+		j := int(state % uint64(i+1))
+		chars[i], chars[j] = chars[j], chars[i]
+	}
+	return string(chars)
+}
+
+func fnvHash(s string) uint64 {
+	h := fnv.New64a()
+	fmt.Fprint(h, s)
+	return h.Sum64()
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"url-shortener/internal/models"
+	"url-shortener/internal/repository"
+)
+
+func TestRandomGenerator(t *testing.T) {
+	g := NewRandomGenerator("ab")
+	for range 100 {
+		code, seq, err := g.Next(8)
+		if err != nil || seq != 0 {
+			t.Fatalf("Next: seq %d, err %v", seq, err)
+		}
+		if len(code) != 8 || strings.Trim(code, "ab") != "" {
+			t.Fatalf("código %q fora do tamanho ou do alfabeto", code)
+		}
+	}
+}
+
+func TestCounterGeneratorIsABijection(t *testing.T) {
+	g := NewCounterGenerator("abcde", "salt")
+	seen := make(map[string]bool)
+
+	// 5^3 = 125 números; o zero não é usado, então são 124 códigos distintos
+	// e depois o espaço se esgota
+	for i := range 124 {
+		code, seq, err := g.Next(3)
+		if err != nil {
+			t.Fatalf("Next %d: %v", i+1, err)
+		}
+		if seq != uint64(i+1) {
+			t.Fatalf("sequência %d, esperado %d", seq, i+1)
+		}
+		if seen[code] {
+			t.Fatalf("código %q repetido na sequência %d", code, seq)
+		}
+		seen[code] = true
+	}
+	if _, _, err := g.Next(3); !errors.Is(err, ErrKeyspaceExhausted) {
+		t.Errorf("espaço esgotado: err = %v, esperado ErrKeyspaceExhausted", err)
+	}
+	if g.Issued() != 124 {
+		t.Errorf("Issued = %d, esperado 124", g.Issued())
+	}
+}
+
+func TestCounterGeneratorSalt(t *testing.T) {
+	first, _, _ := NewCounterGenerator(DefaultCodeAlphabet, "a").Next(6)
+	again, _, _ := NewCounterGenerator(DefaultCodeAlphabet, "a").Next(6)
+	other, _, _ := NewCounterGenerator(DefaultCodeAlphabet, "b").Next(6)
+
+	if first != again {
+		t.Errorf("mesmo salt gerou %q e %q", first, again)
+	}
+	if first == other {
+		t.Errorf("salts diferentes geraram o mesmo código %q", first)
+	}
+}
+
+func TestCounterGeneratorIsNotAffine(t *testing.T) {
+	g := NewCounterGenerator("abcdefghij", "segredo")
+	value := func(code string) int {
+		n := 0
+		for _, c := range code {
+			n = n*len(g.alphabet) + strings.IndexRune(g.alphabet, c)
+		}
+		return n
+	}
+
+	// Numa permutação afim códigos consecutivos teriam diferença constante
+	prev, _, _ := g.Next(6)
+	diffs := make(map[int]bool)
+	for range 20 {
+		code, _, _ := g.Next(6)
+		diffs[value(code)-value(prev)] = true
+		prev = code
+	}
+	if len(diffs) < 10 {
+		t.Errorf("só %d diferenças distintas entre 20 códigos consecutivos", len(diffs))
+	}
+}
+
+func TestCounterGeneratorResume(t *testing.T) {
+	g := NewCounterGenerator(DefaultCodeAlphabet, "")
+	g.Resume(41)
+	if _, seq, _ := g.Next(6); seq != 42 {
+		t.Errorf("sequência %d após Resume(41), esperado 42", seq)
+	}
+	// Resume nunca volta a contagem
+	g.Resume(10)
+	if _, seq, _ := g.Next(6); seq != 43 {
+		t.Errorf("sequência %d após Resume(10), esperado 43", seq)
+	}
+}
+
+func TestSetCodeGeneratorDoesNotReuseRemovedSequences(t *testing.T) {
+	store := repository.NewMemoryStore()
+	service := NewURLService(store, "https://sho.rt")
+	service.Validator().SetResolver(nil)
+	service.SetCodeGenerator(NewCounterGenerator(DefaultCodeAlphabet, "salt"))
+
+	var last *models.CreateURLResponse
+	for range 3 {
+		created, err := service.CreateShortURL(models.CreateURLRequest{URL: "https://example.com"}, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		last = created
+	}
+	code := strings.TrimPrefix(last.ShortURL, "https://sho.rt/")
+	if err := store.Delete(code); err != nil {
+		t.Fatal(err)
+	}
+
+	// Um novo gerador (como no reinício) continua depois do link removido
+	generator := NewCounterGenerator(DefaultCodeAlphabet, "salt")
+	service.SetCodeGenerator(generator)
+	if generator.Issued() != 3 {
+		t.Fatalf("contagem retomada em %d, esperado 3", generator.Issued())
+	}
+	created, err := service.CreateShortURL(models.CreateURLRequest{URL: "https://example.com"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.ShortURL == last.ShortURL {
+		t.Errorf("código %s do link removido foi reutilizado", last.ShortURL)
+	}
+}
+
+func TestKeyspaceUsageCountsIssuedSequences(t *testing.T) {
+	store := repository.NewMemoryStore()
+	service := NewURLService(store, "https://sho.rt")
+	service.Validator().SetResolver(nil)
+	service.SetCodeLength(4)
+	service.SetCodeGenerator(NewCounterGenerator(DefaultCodeAlphabet, ""))
+
+	for range 5 {
+		if _, err := service.CreateShortURL(models.CreateURLRequest{URL: "https://example.com"}, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	usage := service.KeyspaceUsage()
+	if usage.Strategy != StrategyCounter || usage.Used != 5 {
+		t.Errorf("estratégia %q com %d usados, esperado counter com 5", usage.Strategy, usage.Used)
+	}
+	if want := math.Pow(float64(len(DefaultCodeAlphabet)), 4); usage.Capacity != want {
+		t.Errorf("Capacity = %v, esperado %v", usage.Capacity, want)
+	}
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"net/url"
+	"strings"
+	"time"
+	"url-shortener/internal/models"
+	"url-shortener/internal/repository"
+)
+
+// Parâmetros de rastreamento ignorados na comparação de destinos
+var trackingParams = map[string]bool{
+	"fbclid":  true,
+	"gclid":   true,
+	"dclid":   true,
+	"gbraid":  true,
+	"wbraid":  true,
+	"msclkid": true,
+	"yclid":   true,
+	"igshid":  true,
+	"mc_cid":  true,
+	"mc_eid":  true,
+	"_ga":     true,
+	"_gl":     true,
+}
+
+// normalizeDestination gera a chave de deduplicação de uma URL já validada:
+// protocolo e host em minúsculas, sem porta padrão, caminho vazio como "/",
+// parâmetros ordenados e sem parâmetros de rastreamento (utm_* e afins).
+// O fragmento é mantido porque pode identificar conteúdos diferentes.
+func normalizeDestination(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	host := normalizeHost(u.Hostname())
+	if port := u.Port(); port != "" && !(scheme == "http" && port == "80") && !(scheme == "https" && port == "443") {
+		host += ":" + port
+	}
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	query := u.Query()
+	for name := range query {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "utm_") || trackingParams[lower] {
+			query.Del(name)
+		}
+	}
+
+	key := scheme + "://" + host + path
+	if encoded := query.Encode(); encoded != "" { // Encode ordena pelos nomes
+		key += "?" + encoded
+	}
+	if u.Fragment != "" {
+		key += "#" + u.EscapedFragment()
+	}
+	return key
+}
+
+// findDuplicate procura um link ativo do dono no mesmo domínio, para o mesmo
+// destino e com as mesmas opções de expiração e redirecionamento; deve ser chamado com
+// createMu travado
+func (s *URLService) findDuplicate(ownerID, domain, dedupeKey string, req models.CreateURLRequest) *models.URL {
+	index, ok := s.store.(repository.DestinationIndex)
+	if !ok {
+		return nil
+	}
+
+	// Links com regras de roteamento nunca são reaproveitados
+	if len(req.Rules) > 0 {
+		return nil
+	}
+
+	now := time.Now()
+	for _, url := range index.FindByDestination(ownerID, dedupeKey) {
+		if url.Domain != domain || url.IsProtected() || url.IsExpired(now) || url.MaxClicks != req.MaxClicks || len(url.Rules) > 0 {
+			continue
+		}
+		if !sameTime(url.ExpiresAt, req.ExpiresAt) {
+			continue
+		}
+		if redirectStatus(url.RedirectType) != redirectStatus(req.RedirectType) || !sameOpenGraph(url.OpenGraph, req.OpenGraph) {
+			continue
+		}
+		if !sameUTM(url.UTM, req.UTM) || url.ForwardQuery != req.ForwardQuery {
+			continue
+		}
+		return url
+	}
+	return nil
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"url-shortener/internal/models"
+)
+
+var ErrUnknownDomain = errors.New("domínio não configurado")
+
+// AddDomain registra um domínio adicional (URL base como
+// "https://brand-a.link"). Os códigos são independentes por domínio.
+func (s *URLService) AddDomain(baseURL string) error {
+	host, err := domainHost(baseURL)
+	if err != nil {
+		return err
+	}
+	if host == s.primaryHost {
+		return nil
+	}
+
+	s.domains[host] = strings.TrimSuffix(baseURL, "/")
+	s.validator.AddSelfHost(baseURL)
+	return nil
+}
+
+// Domains lista os domínios atendidos, começando pelo principal
+func (s *URLService) Domains() []string {
+	hosts := make([]string, 0, len(s.domains))
+	for host := range s.domains {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	return append([]string{s.primaryHost}, hosts...)
+}
+
+// LinkKey traduz o host da requisição e o código na chave do link no
+// armazenamento. Hosts desconhecidos (IP, localhost, proxies internos)
+// usam o domínio principal.
+func (s *URLService) LinkKey(host, code string) string {
+	// Códigos nunca têm "/"; sem essa checagem /brand.link/abc no domínio
+	// principal chegaria ao link abc de brand.link
+	if strings.Contains(code, "/") {
+		return ""
+	}
+	return models.LinkKey(s.domainOf(host), code)
+}
+
+// domainOf retorna o domínio adicional correspondente ao host ou "" para o
+// domínio principal
+func (s *URLService) domainOf(host string) string {
+	host = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+	if _, ok := s.domains[host]; ok {
+		return host
+	}
+	// Host com porta padrão explícita (brand-a.link:443)
+	if i := strings.LastIndex(host, ":"); i > 0 {
+		if _, ok := s.domains[host[:i]]; ok {
+			return host[:i]
+		}
+	}
+	return ""
+}
+
+// resolveDomain valida o domínio pedido na criação (host ou URL base)
+func (s *URLService) resolveDomain(requested string) (string, error) {
+	requested = strings.TrimSpace(requested)
+	if requested == "" {
+		return "", nil
+	}
+
+	host := strings.ToLower(requested)
+	if strings.Contains(requested, "://") {
+		parsed, err := domainHost(requested)
+		if err != nil {
+			return "", ErrUnknownDomain
+		}
+		host = parsed
+	}
+
+	if host == s.primaryHost {
+		return "", nil
+	}
+	if _, ok := s.domains[host]; !ok {
+		return "", ErrUnknownDomain
+	}
+	return host, nil
+}
+
+// linkURL monta a URL curta do link no domínio dele
+func (s *URLService) linkURL(link *models.URL) string {
+	return s.baseURL(link.Domain) + "/" + link.ShortCode
+}
+
+func (s *URLService) baseURL(domain string) string {
+	if base, ok := s.domains[domain]; ok {
+		return base
+	}
+	return s.domain
+}
+
+// domainHost extrai o host (com porta, se houver) de uma URL base
+func domainHost(baseURL string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(baseURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("domínio inválido %q: use uma URL base como https://exemplo.link", baseURL)
+	}
+	return strings.ToLower(u.Host), nil
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+)
+
+// IPLocator resolve o país (código ISO 3166-1 alpha-2) de um endereço IP.
+// Retorna "" quando o país é desconhecido.
+type IPLocator interface {
+	Country(ip string) string
+}
+
+// NoopLocator não resolve nenhum país
+type NoopLocator struct{}
+
+func (NoopLocator) Country(string) string { return "" }
+
+type cidrEntry struct {
+	network *net.IPNet
+	country string
+}
+
+// CIDRLocator resolve países a partir de uma tabela de faixas CIDR
+type CIDRLocator struct {
+	entries []cidrEntry
+}
+
+// NewCIDRLocator cria um localizador a partir de um mapa CIDR -> país
+func NewCIDRLocator(ranges map[string]string) (*CIDRLocator, error) {
+	l := &CIDRLocator{}
+	for cidr, country := range ranges {
+		if err := l.add(cidr, country); err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// LoadCIDRLocator lê um CSV com linhas "cidr,país"
+func LoadCIDRLocator(path string) (*CIDRLocator, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = 2
+	r.Comment = '#'
+
+	l := &CIDRLocator{}
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := l.add(record[0], record[1]); err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+func (l *CIDRLocator) add(cidr, country string) error {
+	_, network, err := net.ParseCIDR(strings.TrimSpace(cidr))
+	if err != nil {
+		return fmt.Errorf("faixa inválida %q: %w", cidr, err)
+	}
+	l.entries = append(l.entries, cidrEntry{
+		network: network,
+		country: strings.ToUpper(strings.TrimSpace(country)),
+	})
+	return nil
+}
+
+// Country retorna o país da faixa mais específica que contém o IP
+func (l *CIDRLocator) Country(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+
+	country, bestSize := "", -1
+	for _, entry := range l.entries {
+		if !entry.network.Contains(parsed) {
+			continue
+		}
+		if size, _ := entry.network.Mask.Size(); size > bestSize {
+			country, bestSize = entry.country, size
+		}
+	}
+	return country
+}
@@ -0,0 +1,253 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"syscall"
+	"time"
+	"url-shortener/internal/metrics"
+	"url-shortener/internal/models"
+	"url-shortener/internal/repository"
+)
+
+const (
+	defaultCheckInterval    = 6 * time.Hour
+	defaultCheckConcurrency = 8
+	defaultCheckTimeout     = 10 * time.Second
+
+	checkScanInterval = time.Minute     // frequência da busca por links com verificação vencida
+	checkRetryBase    = 5 * time.Minute // primeira nova tentativa após uma falha
+	checkUserAgent    = "url-shortener-linkchecker/1.0"
+	maxCheckBodyRead  = 4 << 10
+)
+
+var (
+	errPrivateDestination = errors.New("destino resolve para endereço privado")
+	errDestinationChanged = errors.New("destino alterado durante a verificação")
+)
+
+// LinkChecker verifica periodicamente se os destinos dos links respondem e
+// grava o resultado em URL.Health. Destinos saudáveis são verificados a cada
+// interval; os que falham são tentados de novo com espera exponencial
+// (5min, 10min, 20min...) limitada a interval.
+type LinkChecker struct {
+	store       repository.Store
+	client      *http.Client
+	interval    time.Duration
+	concurrency int
+}
+
+// NewLinkChecker cria o verificador; valores <= 0 usam os padrões (6h, 8
+// requisições simultâneas e 10s por requisição)
+func NewLinkChecker(store repository.Store, interval time.Duration, concurrency int, timeout time.Duration) *LinkChecker {
+	if interval <= 0 {
+		interval = defaultCheckInterval
+	}
+	if concurrency <= 0 {
+		concurrency = defaultCheckConcurrency
+	}
+	if timeout <= 0 {
+		timeout = defaultCheckTimeout
+	}
+
+	// A validação na criação não impede que o DNS do destino passe a apontar
+	// para a rede interna, então a conexão também recusa endereços privados.
+	// Sem proxy: atrás de um, o dialer só veria o endereço do proxy.
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || isPrivateIP(ip) {
+				return errPrivateDestination
+			}
+			return nil
+		},
+	}
+
+	return &LinkChecker{
+		store: store,
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: &http.Transport{DialContext: dialer.DialContext, Proxy: nil},
+		},
+		interval:    interval,
+		concurrency: concurrency,
+	}
+}
+
+// SetClient troca o cliente HTTP, por exemplo para verificar destinos de um
+// httptest.Server local
+func (c *LinkChecker) SetClient(client *http.Client) {
+	c.client = client
+}
+
+// Start verifica os links vencidos imediatamente e depois a cada minuto.
+// stop interrompe as verificações em andamento e espera o worker terminar.
+func (c *LinkChecker) Start() (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(checkScanInterval)
+		defer ticker.Stop()
+
+		for {
+			if checked := c.CheckDue(ctx, time.Now()); checked > 0 {
+				log.Printf("🔗 %d destino(s) verificado(s)", checked)
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			cancel()
+			<-done
+		})
+	}
+}
+
+// CheckDue verifica os links ativos cuja próxima verificação já venceu e
+// retorna quantos foram verificados
+func (c *LinkChecker) CheckDue(ctx context.Context, now time.Time) int {
+	var due []*models.URL
+	for _, url := range c.store.GetAll() {
+		if url.IsExpired(now) {
+			continue
+		}
+		if url.Health == nil || !now.Before(url.Health.NextCheckAt) {
+			due = append(due, url)
+		}
+	}
+
+	sem := make(chan struct{}, c.concurrency)
+	var wg sync.WaitGroup
+	checked := 0
+
+	for _, url := range due {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return checked
+		}
+
+		checked++
+		wg.Add(1)
+		go func(url *models.URL) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			c.check(ctx, url)
+		}(url)
+	}
+
+	wg.Wait()
+	return checked
+}
+
+// check verifica o destino e grava o resultado no link
+func (c *LinkChecker) check(ctx context.Context, url *models.URL) {
+	status, err := c.probe(ctx, url.OriginalURL)
+	if ctx.Err() != nil {
+		return // encerramento: não registra como falha do destino
+	}
+
+	now := time.Now()
+	_, updateErr := c.store.Update(url.Key(), func(link *models.URL) error {
+		// O destino pode ter mudado durante a verificação
+		if link.OriginalURL != url.OriginalURL {
+			return errDestinationChanged
+		}
+		link.Health = c.nextHealth(link.Health, status, err, now)
+		return nil
+	})
+	if updateErr != nil && !errors.Is(updateErr, repository.ErrNotFound) && !errors.Is(updateErr, repository.ErrExpired) && !errors.Is(updateErr, errDestinationChanged) {
+		log.Printf("⚠️  Erro ao gravar verificação de %s: %v", url.Key(), updateErr)
+	}
+}
+
+// nextHealth calcula o novo estado e a próxima verificação
+func (c *LinkChecker) nextHealth(previous *models.LinkHealth, status int, err error, now time.Time) *models.LinkHealth {
+	health := &models.LinkHealth{StatusCode: status, CheckedAt: now}
+	if previous != nil {
+		health.LastOKAt = previous.LastOKAt
+		health.Failures = previous.Failures
+	}
+
+	switch {
+	case err == nil && status < http.StatusBadRequest:
+		health.Failures = 0
+		health.LastOKAt = &now
+		health.NextCheckAt = now.Add(c.interval)
+		metrics.LinkChecks.Inc("ok")
+		return health
+
+	case status == http.StatusTooManyRequests:
+		// Limite do servidor de destino: tenta mais tarde sem contar falha
+		health.Error = "limite de requisições do destino (429)"
+		health.NextCheckAt = now.Add(c.backoff(health.Failures + 1))
+		return health
+	}
+
+	if err != nil {
+		health.Error = err.Error()
+	} else {
+		health.Error = fmt.Sprintf("status HTTP %d", status)
+	}
+	health.Failures++
+	health.NextCheckAt = now.Add(c.backoff(health.Failures))
+	metrics.LinkChecks.Inc("broken")
+	return health
+}
+
+// backoff retorna a espera antes da tentativa seguinte à falha n
+func (c *LinkChecker) backoff(failures int) time.Duration {
+	wait := checkRetryBase
+	for i := 1; i < failures && wait < c.interval; i++ {
+		wait *= 2
+	}
+	return min(wait, c.interval)
+}
+
+// probe faz HEAD no destino e, se o servidor não aceitar HEAD, GET
+func (c *LinkChecker) probe(ctx context.Context, destination string) (int, error) {
+	status, err := c.request(ctx, http.MethodHead, destination)
+	if err == nil && (status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented || status == http.StatusForbidden) {
+		return c.request(ctx, http.MethodGet, destination)
+	}
+	return status, err
+}
+
+func (c *LinkChecker) request(ctx context.Context, method, destination string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, destination, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", checkUserAgent)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// Lê um pouco do corpo para a conexão poder ser reaproveitada
+	io.CopyN(io.Discard, resp.Body, maxCheckBodyRead)
+	return resp.StatusCode, nil
+}
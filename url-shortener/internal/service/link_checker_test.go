@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+	"url-shortener/internal/models"
+	"url-shortener/internal/repository"
+)
+
+// destinationServer responde conforme o caminho: /ok, /gone (404), /busy
+// (429), /no-head (405 no HEAD, 200 no GET) e /flaky (500 até healthy)
+func destinationServer(t *testing.T, healthy *atomic.Bool) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+		case "/gone":
+			w.WriteHeader(http.StatusNotFound)
+		case "/busy":
+			w.WriteHeader(http.StatusTooManyRequests)
+		case "/no-head":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		case "/flaky":
+			if !healthy.Load() {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestChecker(t *testing.T, store repository.Store, server *httptest.Server, interval time.Duration) *LinkChecker {
+	t.Helper()
+	checker := NewLinkChecker(store, interval, 2, time.Second)
+	// O dialer padrão recusa o endereço de loopback do servidor de teste
+	checker.SetClient(server.Client())
+	return checker
+}
+
+func saveLink(t *testing.T, store repository.Store, code, destination, ownerID string) {
+	t.Helper()
+	err := store.Save(&models.URL{
+		ID:          "id-" + code,
+		OriginalURL: destination,
+		ShortCode:   code,
+		OwnerID:     ownerID,
+		CreatedAt:   time.Now(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func healthOf(t *testing.T, store repository.Store, code string) *models.LinkHealth {
+	t.Helper()
+	url, err := store.FindByShortCode(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url.Health == nil {
+		t.Fatalf("%s sem resultado de verificação", code)
+	}
+	return url.Health
+}
+
+func TestLinkCheckerRecordsStatus(t *testing.T) {
+	store := repository.NewMemoryStore()
+	server := destinationServer(t, new(atomic.Bool))
+	checker := newTestChecker(t, store, server, time.Hour)
+
+	for _, path := range []string{"ok", "gone", "busy", "no-head"} {
+		saveLink(t, store, path, server.URL+"/"+path, "owner")
+	}
+
+	if checked := checker.CheckDue(context.Background(), time.Now()); checked != 4 {
+		t.Fatalf("CheckDue verificou %d links, esperado 4", checked)
+	}
+
+	tests := []struct {
+		code     string
+		status   int
+		failures int
+	}{
+		{"ok", http.StatusOK, 0},
+		{"gone", http.StatusNotFound, 1},
+		{"busy", http.StatusTooManyRequests, 0}, // 429 não conta como falha
+		{"no-head", http.StatusOK, 0},           // refeito com GET
+	}
+	for _, tt := range tests {
+		health := healthOf(t, store, tt.code)
+		if health.StatusCode != tt.status || health.Failures != tt.failures {
+			t.Errorf("%s: status %d com %d falhas, esperado %d com %d", tt.code, health.StatusCode, health.Failures, tt.status, tt.failures)
+		}
+		if (health.LastOKAt != nil) != (tt.status == http.StatusOK) {
+			t.Errorf("%s: LastOKAt = %v", tt.code, health.LastOKAt)
+		}
+	}
+
+	// Nada vence antes da próxima verificação
+	if checked := checker.CheckDue(context.Background(), time.Now()); checked != 0 {
+		t.Errorf("CheckDue verificou %d links antes de vencerem", checked)
+	}
+}
+
+func TestLinkCheckerBacksOffFailures(t *testing.T) {
+	store := repository.NewMemoryStore()
+	healthy := new(atomic.Bool)
+	server := destinationServer(t, healthy)
+	interval := 15 * time.Minute
+	checker := newTestChecker(t, store, server, interval)
+
+	saveLink(t, store, "flaky", server.URL+"/flaky", "owner")
+
+	// 5min, 10min e então o intervalo normal como teto
+	now := time.Now()
+	for i, wait := range []time.Duration{5 * time.Minute, 10 * time.Minute, interval, interval} {
+		if checked := checker.CheckDue(context.Background(), now); checked != 1 {
+			t.Fatalf("verificação %d: CheckDue verificou %d links", i+1, checked)
+		}
+
+		health := healthOf(t, store, "flaky")
+		if health.Failures != i+1 {
+			t.Fatalf("verificação %d: %d falhas, esperado %d", i+1, health.Failures, i+1)
+		}
+		if got := health.NextCheckAt.Sub(health.CheckedAt); got != wait {
+			t.Errorf("verificação %d: próxima em %v, esperado %v", i+1, got, wait)
+		}
+		if health.Error == "" {
+			t.Errorf("verificação %d: erro não registrado", i+1)
+		}
+		now = health.NextCheckAt
+	}
+
+	// Uma verificação bem-sucedida zera as falhas
+	healthy.Store(true)
+	checker.CheckDue(context.Background(), healthOf(t, store, "flaky").NextCheckAt)
+	health := healthOf(t, store, "flaky")
+	if health.Failures != 0 || health.Error != "" || health.LastOKAt == nil {
+		t.Errorf("após recuperar: %+v", health)
+	}
+	if got := health.NextCheckAt.Sub(health.CheckedAt); got != interval {
+		t.Errorf("próxima verificação em %v, esperado %v", got, interval)
+	}
+}
+
+func TestLinkCheckerSkipsExpiredLinks(t *testing.T) {
+	store := repository.NewMemoryStore()
+	server := destinationServer(t, new(atomic.Bool))
+	checker := newTestChecker(t, store, server, time.Hour)
+
+	expiredAt := time.Now().Add(-time.Minute)
+	err := store.Save(&models.URL{ID: "id-old", OriginalURL: server.URL + "/gone", ShortCode: "old", CreatedAt: time.Now(), ExpiresAt: &expiredAt})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checked := checker.CheckDue(context.Background(), time.Now()); checked != 0 {
+		t.Errorf("CheckDue verificou %d links expirados", checked)
+	}
+}
+
+func TestBrokenLinks(t *testing.T) {
+	store := repository.NewMemoryStore()
+	server := destinationServer(t, new(atomic.Bool))
+	checker := newTestChecker(t, store, server, time.Hour)
+	service := NewURLService(store, "https://sho.rt")
+
+	saveLink(t, store, "fine", server.URL+"/ok", "owner")
+	saveLink(t, store, "dead", server.URL+"/gone", "owner")
+	saveLink(t, store, "busy", server.URL+"/busy", "owner")
+	saveLink(t, store, "other", server.URL+"/gone", "someone-else")
+	checker.CheckDue(context.Background(), time.Now())
+
+	// Ainda não verificado: não aparece como quebrado
+	saveLink(t, store, "unchecked", server.URL+"/gone", "owner")
+
+	resp := service.BrokenLinks("owner", 10, 0)
+	if resp.Total != 1 || len(resp.Links) != 1 || resp.Links[0].ShortCode != "dead" {
+		codes := make([]string, 0, len(resp.Links))
+		for _, url := range resp.Links {
+			codes = append(codes, url.ShortCode)
+		}
+		t.Fatalf("BrokenLinks = %v (total %d), esperado só dead", codes, resp.Total)
+	}
+	if resp.Links[0].Health.StatusCode != http.StatusNotFound {
+		t.Errorf("status = %d, esperado 404", resp.Links[0].Health.StatusCode)
+	}
+}
+
+func TestLinkCheckerIgnoresProxyEnvironment(t *testing.T) {
+	// Atrás de um proxy o dialer só veria o endereço dele e deixaria de
+	// recusar destinos privados
+	t.Setenv("HTTP_PROXY", "http://proxy.example.com:3128")
+	t.Setenv("HTTPS_PROXY", "http://proxy.example.com:3128")
+
+	checker := NewLinkChecker(repository.NewMemoryStore(), 0, 0, 0)
+	transport, ok := checker.client.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("transporte %T, esperado *http.Transport", checker.client.Transport)
+	}
+	if transport.Proxy != nil {
+		t.Error("o verificador não deve usar o proxy do ambiente")
+	}
+
+	// Um destino na rede interna continua recusado na conexão
+	req, _ := http.NewRequest(http.MethodHead, "http://127.0.0.1:9/", nil)
+	if _, err := checker.client.Do(req); !errors.Is(err, errPrivateDestination) {
+		t.Errorf("err = %v, esperado errPrivateDestination", err)
+	}
+}
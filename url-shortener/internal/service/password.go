@@ -0,0 +1,140 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+	"url-shortener/internal/models"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	minPasswordLength = 4
+	maxPasswordLength = 72 // limite do bcrypt
+
+	// Após maxUnlockFailures senhas erradas em unlockWindow o link fica
+	// bloqueado para novas tentativas por unlockLockout
+	maxUnlockFailures = 5
+	unlockWindow      = 15 * time.Minute
+	unlockLockout     = 15 * time.Minute
+	maxUnlockEntries  = 10000 // acima disso as entradas vencidas são descartadas
+)
+
+var (
+	ErrInvalidPassword         = fmt.Errorf("password deve ter de %d a %d caracteres", minPasswordLength, maxPasswordLength)
+	ErrPasswordRequiresAccount = errors.New("links com senha exigem uma conta (chave de API)")
+	ErrPasswordRequired        = errors.New("link protegido por senha")
+	ErrWrongPassword           = errors.New("senha incorreta")
+	ErrLinkLocked              = errors.New("muitas tentativas incorretas; tente novamente mais tarde")
+	ErrNotProtected            = errors.New("link não é protegido por senha")
+	ErrStatsForbidden          = errors.New("estatísticas de links protegidos exigem a chave de API do dono")
+)
+
+// hashPassword valida e gera o hash bcrypt da senha do link
+func hashPassword(password string) (string, error) {
+	if len(password) < minPasswordLength || len(password) > maxPasswordLength {
+		return "", ErrInvalidPassword
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// UnlockURL confere a senha de um link protegido e, se correta, resolve e
+// registra o clique como GetOriginalURL. O redirecionamento usa 303 para o
+// navegador seguir com GET após o POST do formulário. Links sem senha
+// retornam ErrNotProtected sem registrar clique.
+func (s *URLService) UnlockURL(shortCode, password string, info models.RequestInfo) (*models.Redirect, error) {
+	url, err := s.findActive(shortCode)
+	if err != nil {
+		return nil, err
+	}
+	if !url.IsProtected() {
+		return nil, ErrNotProtected
+	}
+
+	// A tentativa é contada antes da comparação para que requisições
+	// simultâneas não escapem do limite
+	if !s.unlocks.attempt(shortCode, time.Now()) {
+		return nil, ErrLinkLocked
+	}
+	if bcrypt.CompareHashAndPassword([]byte(url.PasswordHash), []byte(password)) != nil {
+		return nil, ErrWrongPassword
+	}
+	s.unlocks.reset(shortCode)
+
+	redirect, err := s.follow(url, shortCode, info)
+	if err != nil {
+		return nil, err
+	}
+	redirect.Status = http.StatusSeeOther
+	return redirect, nil
+}
+
+// unlockGuard conta as tentativas de senha por link
+type unlockGuard struct {
+	mu      sync.Mutex
+	entries map[string]*unlockEntry
+}
+
+type unlockEntry struct {
+	failures    int
+	windowStart time.Time
+	lockedUntil time.Time
+}
+
+func newUnlockGuard() *unlockGuard {
+	return &unlockGuard{entries: make(map[string]*unlockEntry)}
+}
+
+// attempt registra uma tentativa e retorna false se o link está bloqueado
+func (g *unlockGuard) attempt(key string, now time.Time) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	entry, ok := g.entries[key]
+	if !ok {
+		if len(g.entries) >= maxUnlockEntries {
+			g.pruneLocked(now)
+		}
+		entry = &unlockEntry{windowStart: now}
+		g.entries[key] = entry
+	}
+
+	if now.Before(entry.lockedUntil) {
+		return false
+	}
+	if now.Sub(entry.windowStart) > unlockWindow {
+		entry.failures = 0
+		entry.windowStart = now
+	}
+
+	entry.failures++
+	if entry.failures > maxUnlockFailures {
+		entry.lockedUntil = now.Add(unlockLockout)
+		entry.failures = 0
+		entry.windowStart = entry.lockedUntil
+		return false
+	}
+	return true
+}
+
+// reset limpa as tentativas após a senha correta
+func (g *unlockGuard) reset(key string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.entries, key)
+}
+
+func (g *unlockGuard) pruneLocked(now time.Time) {
+	for key, entry := range g.entries {
+		if now.After(entry.lockedUntil) && now.Sub(entry.windowStart) > unlockWindow {
+			delete(g.entries, key)
+		}
+	}
+}
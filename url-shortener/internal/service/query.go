@@ -0,0 +1,145 @@
+package service
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"url-shortener/internal/models"
+)
+
+const maxUTMValue = 200
+
+var ErrInvalidUTM = fmt.Errorf("utm inválido: cada campo pode ter até %d caracteres", maxUTMValue)
+
+// queryParam é um parâmetro já codificado da query string
+type queryParam struct {
+	name string // nome decodificado, usado na comparação
+	raw  string // "nome=valor" como aparece na URL
+}
+
+// buildDestination aplica ao destino escolhido os parâmetros UTM do link e, se o link
+// permitir, a query string recebida na URL curta. Parâmetros repetidos são
+// substituídos na posição original; os novos vão para o final. A precedência
+// é destino < UTM < query recebida. O fragmento do destino é preservado.
+func buildDestination(link *models.URL, destination, forwardedQuery string) (string, error) {
+	var extra []queryParam
+	if link.UTM != nil {
+		extra = append(extra, utmParams(link.UTM)...)
+	}
+	if link.ForwardQuery && forwardedQuery != "" {
+		extra = append(extra, parseQuery(forwardedQuery)...)
+	}
+	if len(extra) == 0 {
+		return destination, nil
+	}
+
+	u, err := url.Parse(destination)
+	if err != nil {
+		return "", err
+	}
+
+	params := parseQuery(u.RawQuery)
+	for _, param := range extra {
+		params = setParam(params, param)
+	}
+
+	raw := make([]string, len(params))
+	for i, param := range params {
+		raw[i] = param.raw
+	}
+	u.RawQuery = strings.Join(raw, "&")
+	u.ForceQuery = false
+	return u.String(), nil
+}
+
+// parseQuery separa a query mantendo a ordem e a codificação originais
+func parseQuery(rawQuery string) []queryParam {
+	var params []queryParam
+	for _, part := range strings.FieldsFunc(rawQuery, func(r rune) bool { return r == '&' }) {
+		name, _, _ := strings.Cut(part, "=")
+		if decoded, err := url.QueryUnescape(name); err == nil {
+			name = decoded
+		}
+		if name == "" {
+			continue
+		}
+		params = append(params, queryParam{name: name, raw: part})
+	}
+	return params
+}
+
+// setParam substitui todas as ocorrências do parâmetro pela nova, na
+// posição da primeira, ou o adiciona ao final
+func setParam(params []queryParam, param queryParam) []queryParam {
+	result := params[:0:0]
+	replaced := false
+	for _, existing := range params {
+		if existing.name != param.name {
+			result = append(result, existing)
+			continue
+		}
+		if !replaced {
+			result = append(result, param)
+			replaced = true
+		}
+	}
+	if !replaced {
+		result = append(result, param)
+	}
+	return result
+}
+
+// utmParams converte os campos preenchidos em parâmetros utm_*
+func utmParams(utm *models.UTMParams) []queryParam {
+	fields := []struct{ name, value string }{
+		{"utm_source", utm.Source},
+		{"utm_medium", utm.Medium},
+		{"utm_campaign", utm.Campaign},
+		{"utm_term", utm.Term},
+		{"utm_content", utm.Content},
+	}
+
+	var params []queryParam
+	for _, field := range fields {
+		if field.value != "" {
+			params = append(params, queryParam{
+				name: field.name,
+				raw:  field.name + "=" + url.QueryEscape(field.value),
+			})
+		}
+	}
+	return params
+}
+
+// normalizeUTM remove espaços e valida os campos; retorna nil quando todos
+// estão vazios
+func normalizeUTM(utm *models.UTMParams) (*models.UTMParams, error) {
+	if utm == nil {
+		return nil, nil
+	}
+
+	normalized := &models.UTMParams{
+		Source:   strings.TrimSpace(utm.Source),
+		Medium:   strings.TrimSpace(utm.Medium),
+		Campaign: strings.TrimSpace(utm.Campaign),
+		Term:     strings.TrimSpace(utm.Term),
+		Content:  strings.TrimSpace(utm.Content),
+	}
+	if *normalized == (models.UTMParams{}) {
+		return nil, nil
+	}
+
+	for _, value := range []string{normalized.Source, normalized.Medium, normalized.Campaign, normalized.Term, normalized.Content} {
+		if len([]rune(value)) > maxUTMValue {
+			return nil, ErrInvalidUTM
+		}
+	}
+	return normalized, nil
+}
+
+func sameUTM(a, b *models.UTMParams) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
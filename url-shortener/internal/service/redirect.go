@@ -0,0 +1,81 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"url-shortener/internal/models"
+)
+
+const (
+	defaultRedirectType = http.StatusFound
+
+	maxOGTitle       = 200
+	maxOGDescription = 500
+)
+
+var (
+	ErrInvalidRedirectType = errors.New("redirect_type deve ser 301, 302, 307 ou 308")
+	ErrInvalidOpenGraph    = fmt.Errorf("open_graph inválido: title até %d caracteres, description até %d e image deve ser uma URL http(s)", maxOGTitle, maxOGDescription)
+)
+
+// 301 e 308 são permanentes: o navegador guarda o destino em cache e deixa
+// de passar pelo encurtador, perdendo cliques e alterações do link
+var redirectTypes = map[int]bool{
+	http.StatusMovedPermanently:  true,
+	http.StatusFound:             true,
+	http.StatusTemporaryRedirect: true,
+	http.StatusPermanentRedirect: true,
+}
+
+func validateRedirectType(status int) error {
+	if status != 0 && !redirectTypes[status] {
+		return ErrInvalidRedirectType
+	}
+	return nil
+}
+
+// redirectStatus retorna o status HTTP do redirecionamento do link
+func redirectStatus(redirectType int) int {
+	if redirectType == 0 {
+		return defaultRedirectType
+	}
+	return redirectType
+}
+
+// normalizeOpenGraph remove espaços e valida os metadados; retorna nil
+// quando todos os campos estão vazios
+func normalizeOpenGraph(og *models.OpenGraph) (*models.OpenGraph, error) {
+	if og == nil {
+		return nil, nil
+	}
+
+	normalized := &models.OpenGraph{
+		Title:       strings.TrimSpace(og.Title),
+		Description: strings.TrimSpace(og.Description),
+		Image:       strings.TrimSpace(og.Image),
+	}
+	if *normalized == (models.OpenGraph{}) {
+		return nil, nil
+	}
+
+	if len([]rune(normalized.Title)) > maxOGTitle || len([]rune(normalized.Description)) > maxOGDescription {
+		return nil, ErrInvalidOpenGraph
+	}
+	if normalized.Image != "" {
+		u, err := url.Parse(normalized.Image)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, ErrInvalidOpenGraph
+		}
+	}
+	return normalized, nil
+}
+
+func sameOpenGraph(a, b *models.OpenGraph) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,292 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"time"
+	"url-shortener/internal/models"
+)
+
+const (
+	maxRules         = 20
+	maxSplitVariants = 10
+	maxSplitWeight   = 1000
+	maxRuleIDLength  = 32
+)
+
+var ErrInvalidRule = errors.New("regra de roteamento inválida")
+
+var (
+	ruleDevices = map[string]bool{DeviceDesktop: true, DeviceMobile: true, DeviceTablet: true, DeviceBot: true}
+	ruleOS      = map[string]bool{OSiOS: true, OSAndroid: true, OSWindows: true, OSMacOS: true, OSChromeOS: true, OSLinux: true}
+)
+
+// visitorContext reúne os atributos do visitante usados pelas regras
+type visitorContext struct {
+	Device    string
+	OS        string
+	Language  string // idioma preferido do Accept-Language, em minúsculas
+	Country   string
+	Time      time.Time
+	VisitorID string
+}
+
+// normalizeRules valida as regras, normaliza as listas de condições e gera
+// os identificadores ausentes. validate é a validação de destinos do serviço.
+func normalizeRules(rules []models.RoutingRule, validate func(string) (string, error)) ([]models.RoutingRule, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	if len(rules) > maxRules {
+		return nil, fmt.Errorf("%w: máximo de %d regras por link", ErrInvalidRule, maxRules)
+	}
+
+	normalized := make([]models.RoutingRule, len(rules))
+	ids := make(map[string]bool)
+
+	for i, rule := range rules {
+		invalid := func(reason string) error {
+			return ruleError(i, reason)
+		}
+
+		rule.ID = strings.TrimSpace(rule.ID)
+		if rule.ID == "" {
+			rule.ID = "rule-" + strconv.Itoa(i+1)
+		}
+		if !validRuleID(rule.ID) {
+			return nil, invalid(fmt.Sprintf("id deve ter até %d letras, números, '-' ou '_'", maxRuleIDLength))
+		}
+		if ids[rule.ID] {
+			return nil, invalid("id repetido")
+		}
+		ids[rule.ID] = true
+
+		if (rule.Destination == "") == (len(rule.Split) == 0) {
+			return nil, invalid("informe destination ou split")
+		}
+		if rule.Destination != "" {
+			destination, err := validate(rule.Destination)
+			if err != nil {
+				return nil, ruleDestinationError(i, err)
+			}
+			rule.Destination = destination
+		}
+
+		split, err := normalizeSplit(i, rule.Split, validate)
+		if err != nil {
+			return nil, err
+		}
+		rule.Split = split
+
+		if rule.Devices, err = normalizeValues(rule.Devices, strings.ToLower, ruleDevices); err != nil {
+			return nil, invalid("device desconhecido")
+		}
+		if rule.OS, err = normalizeValues(rule.OS, strings.ToLower, ruleOS); err != nil {
+			return nil, invalid("os desconhecido")
+		}
+		if rule.Languages, err = normalizeValues(rule.Languages, strings.ToLower, nil); err != nil {
+			return nil, invalid("idioma inválido")
+		}
+		if rule.Countries, err = normalizeValues(rule.Countries, strings.ToUpper, nil); err != nil {
+			return nil, invalid("país inválido")
+		}
+		for _, country := range rule.Countries {
+			if len(country) != 2 {
+				return nil, invalid("use códigos de país com duas letras")
+			}
+		}
+
+		if w := rule.TimeWindow; w != nil {
+			if w.Start == nil && w.End == nil {
+				rule.TimeWindow = nil
+			} else if w.Start != nil && w.End != nil && !w.Start.Before(*w.End) {
+				return nil, invalid("time_window.start deve ser anterior a time_window.end")
+			}
+		}
+
+		normalized[i] = rule
+	}
+	return normalized, nil
+}
+
+func normalizeSplit(n int, split []models.WeightedDestination, validate func(string) (string, error)) ([]models.WeightedDestination, error) {
+	if len(split) == 0 {
+		return nil, nil
+	}
+	if len(split) < 2 || len(split) > maxSplitVariants {
+		return nil, ruleError(n, fmt.Sprintf("split deve ter de 2 a %d variantes", maxSplitVariants))
+	}
+
+	normalized := make([]models.WeightedDestination, len(split))
+	labels := make(map[string]bool)
+	for i, variant := range split {
+		variant.Label = strings.TrimSpace(variant.Label)
+		if variant.Label == "" {
+			variant.Label = string(rune('a' + i))
+		}
+		if !validRuleID(variant.Label) || labels[variant.Label] {
+			return nil, ruleError(n, "label de variante inválido ou repetido")
+		}
+		labels[variant.Label] = true
+
+		if variant.Weight < 1 || variant.Weight > maxSplitWeight {
+			return nil, ruleError(n, fmt.Sprintf("weight deve estar entre 1 e %d", maxSplitWeight))
+		}
+
+		destination, err := validate(variant.Destination)
+		if err != nil {
+			return nil, ruleDestinationError(n, err)
+		}
+		variant.Destination = destination
+		normalized[i] = variant
+	}
+	return normalized, nil
+}
+
+// ruleError descreve o problema da n-ésima regra (a partir de zero)
+func ruleError(n int, reason string) error {
+	return fmt.Errorf("%w: regra %d: %s", ErrInvalidRule, n+1, reason)
+}
+
+// ruleDestinationError mantém o erro da validação de destino para que o
+// handler responda com o mesmo status da URL principal
+func ruleDestinationError(n int, err error) error {
+	return fmt.Errorf("regra %d: %w", n+1, err)
+}
+
+// normalizeValues aplica transform, remove vazios e, com allowed, recusa
+// valores desconhecidos
+func normalizeValues(values []string, transform func(string) string, allowed map[string]bool) ([]string, error) {
+	var result []string
+	for _, value := range values {
+		value = transform(strings.TrimSpace(value))
+		if value == "" {
+			continue
+		}
+		if allowed != nil && !allowed[value] {
+			return nil, ErrInvalidRule
+		}
+		result = append(result, value)
+	}
+	return result, nil
+}
+
+func validRuleID(id string) bool {
+	if id == "" || len(id) > maxRuleIDLength {
+		return false
+	}
+	for _, c := range id {
+		isAlnum := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+		if !isAlnum && c != '-' && c != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// matchRule retorna a primeira regra que casa com o visitante e o destino
+// escolhido (com a variante, em divisões A/B)
+func matchRule(rules []models.RoutingRule, visitor visitorContext) (rule *models.RoutingRule, destination, variant string) {
+	for i := range rules {
+		rule := &rules[i]
+		if !ruleMatches(rule, visitor) {
+			continue
+		}
+		if len(rule.Split) == 0 {
+			return rule, rule.Destination, ""
+		}
+		chosen := pickVariant(rule, visitor.VisitorID)
+		return rule, chosen.Destination, chosen.Label
+	}
+	return nil, "", ""
+}
+
+func ruleMatches(rule *models.RoutingRule, visitor visitorContext) bool {
+	if len(rule.Devices) > 0 && !contains(rule.Devices, visitor.Device) {
+		return false
+	}
+	if len(rule.OS) > 0 && !contains(rule.OS, visitor.OS) {
+		return false
+	}
+	if len(rule.Countries) > 0 && !contains(rule.Countries, visitor.Country) {
+		return false
+	}
+	if len(rule.Languages) > 0 && !languageMatches(rule.Languages, visitor.Language) {
+		return false
+	}
+	if rule.TimeWindow != nil && !rule.TimeWindow.Contains(visitor.Time) {
+		return false
+	}
+	return true
+}
+
+// pickVariant escolhe a variante pelo hash do visitante, de modo que o
+// mesmo visitante sempre caia na mesma variante
+func pickVariant(rule *models.RoutingRule, visitorID string) models.WeightedDestination {
+	total := 0
+	for _, variant := range rule.Split {
+		total += variant.Weight
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(rule.ID + "|" + visitorID))
+	point := int(h.Sum32() % uint32(total))
+
+	for _, variant := range rule.Split {
+		if point < variant.Weight {
+			return variant
+		}
+		point -= variant.Weight
+	}
+	return rule.Split[len(rule.Split)-1]
+}
+
+// languageMatches aceita o idioma exato ou pelo prefixo ("pt" casa com "pt-br")
+func languageMatches(languages []string, language string) bool {
+	if language == "" {
+		return false
+	}
+	for _, candidate := range languages {
+		if language == candidate || strings.HasPrefix(language, candidate+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+// preferredLanguage retorna o idioma de maior peso do Accept-Language
+func preferredLanguage(acceptLanguage string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q > bestQ {
+			best, bestQ = tag, q
+		}
+	}
+	return best
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
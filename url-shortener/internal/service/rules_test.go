@@ -0,0 +1,175 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"url-shortener/internal/models"
+)
+
+// acceptAll é uma validação de destinos que só exige o prefixo https://
+func acceptAll(raw string) (string, error) {
+	if !strings.HasPrefix(raw, "https://") {
+		return "", ErrInvalidURL
+	}
+	return raw, nil
+}
+
+func TestNormalizeRules(t *testing.T) {
+	rules, err := normalizeRules([]models.RoutingRule{
+		{Destination: "https://m.example.com", Devices: []string{" Mobile ", ""}, Countries: []string{"br"}},
+		{ID: "ab", Split: []models.WeightedDestination{
+			{Destination: "https://a.example.com", Weight: 1},
+			{Destination: "https://b.example.com", Weight: 3},
+		}, Languages: []string{"PT-BR"}},
+	}, acceptAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rules[0].ID != "rule-1" {
+		t.Errorf("ID gerado = %q, esperado rule-1", rules[0].ID)
+	}
+	if len(rules[0].Devices) != 1 || rules[0].Devices[0] != DeviceMobile {
+		t.Errorf("Devices = %v, esperado [mobile]", rules[0].Devices)
+	}
+	if rules[0].Countries[0] != "BR" || rules[1].Languages[0] != "pt-br" {
+		t.Errorf("Countries = %v, Languages = %v, esperado BR e pt-br", rules[0].Countries, rules[1].Languages)
+	}
+	if rules[1].Split[0].Label != "a" || rules[1].Split[1].Label != "b" {
+		t.Errorf("labels gerados = %q, %q, esperado a e b", rules[1].Split[0].Label, rules[1].Split[1].Label)
+	}
+
+	if rules, err := normalizeRules(nil, acceptAll); rules != nil || err != nil {
+		t.Errorf("sem regras: %v, %v", rules, err)
+	}
+}
+
+func TestNormalizeRulesRejects(t *testing.T) {
+	now := time.Now()
+	later := now.Add(time.Hour)
+	split := func(weights ...int) []models.WeightedDestination {
+		var variants []models.WeightedDestination
+		for _, weight := range weights {
+			variants = append(variants, models.WeightedDestination{Destination: "https://x.example.com", Weight: weight})
+		}
+		return variants
+	}
+
+	tests := map[string]models.RoutingRule{
+		"sem destino":           {Devices: []string{"mobile"}},
+		"destino e split":       {Destination: "https://x.example.com", Split: split(1, 1)},
+		"split de uma variante": {Split: split(1)},
+		"peso zero":             {Split: split(1, 0)},
+		"peso acima do máximo":  {Split: split(1, maxSplitWeight+1)},
+		"id inválido":           {ID: "com espaço", Destination: "https://x.example.com"},
+		"device desconhecido":   {Destination: "https://x.example.com", Devices: []string{"fridge"}},
+		"os desconhecido":       {Destination: "https://x.example.com", OS: []string{"beos"}},
+		"país com três letras":  {Destination: "https://x.example.com", Countries: []string{"BRA"}},
+		"janela invertida":      {Destination: "https://x.example.com", TimeWindow: &models.TimeWindow{Start: &later, End: &now}},
+	}
+	for name, rule := range tests {
+		if _, err := normalizeRules([]models.RoutingRule{rule}, acceptAll); !errors.Is(err, ErrInvalidRule) {
+			t.Errorf("%s: err = %v, esperado ErrInvalidRule", name, err)
+		}
+	}
+
+	// O erro da validação de destino é mantido para o handler
+	_, err := normalizeRules([]models.RoutingRule{{Destination: "javascript:alert(1)"}}, acceptAll)
+	if !errors.Is(err, ErrInvalidURL) {
+		t.Errorf("destino inválido: err = %v, esperado ErrInvalidURL", err)
+	}
+
+	dup := models.RoutingRule{ID: "x", Destination: "https://x.example.com"}
+	if _, err := normalizeRules([]models.RoutingRule{dup, dup}, acceptAll); !errors.Is(err, ErrInvalidRule) {
+		t.Errorf("id repetido: err = %v, esperado ErrInvalidRule", err)
+	}
+
+	tooMany := make([]models.RoutingRule, maxRules+1)
+	for i := range tooMany {
+		tooMany[i] = models.RoutingRule{Destination: "https://x.example.com"}
+	}
+	if _, err := normalizeRules(tooMany, acceptAll); !errors.Is(err, ErrInvalidRule) {
+		t.Errorf("regras demais: err = %v, esperado ErrInvalidRule", err)
+	}
+}
+
+func TestMatchRuleOrder(t *testing.T) {
+	start := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	end := start.Add(8 * time.Hour)
+	rules := []models.RoutingRule{
+		{ID: "ios-br", Destination: "https://ios-br.example.com", OS: []string{OSiOS}, Countries: []string{"BR"}},
+		{ID: "mobile", Destination: "https://m.example.com", Devices: []string{DeviceMobile}},
+		{ID: "pt", Destination: "https://pt.example.com", Languages: []string{"pt"}},
+		{ID: "office", Destination: "https://office.example.com", TimeWindow: &models.TimeWindow{Start: &start, End: &end}},
+	}
+	night := start.Add(-time.Hour)
+
+	tests := []struct {
+		name    string
+		visitor visitorContext
+		want    string
+	}{
+		{"primeira regra vence", visitorContext{Device: DeviceMobile, OS: OSiOS, Country: "BR", Time: night}, "ios-br"},
+		{"todas as condições", visitorContext{Device: DeviceMobile, OS: OSiOS, Country: "PT", Time: night}, "mobile"},
+		{"prefixo de idioma", visitorContext{Device: DeviceDesktop, Language: "pt-br", Time: night}, "pt"},
+		{"idioma diferente", visitorContext{Device: DeviceDesktop, Language: "ptx", Time: start}, "office"},
+		{"fim da janela é aberto", visitorContext{Device: DeviceDesktop, Time: end}, ""},
+	}
+	for _, tt := range tests {
+		rule, destination, variant := matchRule(rules, tt.visitor)
+		got := ""
+		if rule != nil {
+			got = rule.ID
+			if destination != rule.Destination {
+				t.Errorf("%s: destino = %q, esperado %q", tt.name, destination, rule.Destination)
+			}
+		}
+		if got != tt.want || variant != "" {
+			t.Errorf("%s: regra = %q (variante %q), esperado %q", tt.name, got, variant, tt.want)
+		}
+	}
+}
+
+func TestMatchRuleSplitIsStable(t *testing.T) {
+	rules := []models.RoutingRule{{ID: "ab", Split: []models.WeightedDestination{
+		{Label: "a", Destination: "https://a.example.com", Weight: 1},
+		{Label: "b", Destination: "https://b.example.com", Weight: 3},
+	}}}
+
+	counts := make(map[string]int)
+	for i := range 4000 {
+		visitor := visitorContext{VisitorID: fmt.Sprintf("visitor-%d", i)}
+		_, destination, variant := matchRule(rules, visitor)
+		if want := "https://" + variant + ".example.com"; destination != want {
+			t.Fatalf("variante %q com destino %q", variant, destination)
+		}
+		if _, again, _ := matchRule(rules, visitor); again != destination {
+			t.Fatalf("visitante %d mudou de variante", i)
+		}
+		counts[variant]++
+	}
+
+	// Pesos 1:3, com folga para a distribuição do hash
+	if counts["a"] < 800 || counts["a"] > 1200 {
+		t.Errorf("variante a recebeu %d de 4000 visitantes, esperado cerca de 1000", counts["a"])
+	}
+}
+
+func TestPreferredLanguage(t *testing.T) {
+	tests := map[string]string{
+		"":                             "",
+		"pt-BR":                        "pt-br",
+		"en;q=0.5, pt-BR;q=0.9, *":     "pt-br",
+		"fr, en;q=0.8":                 "fr",
+		"*;q=1, de;q=0.3, es;q=inval":  "de",
+		" en-US ; q=0.7 ,ja ; q=0.71 ": "ja",
+	}
+	for header, want := range tests {
+		if got := preferredLanguage(header); got != want {
+			t.Errorf("preferredLanguage(%q) = %q, esperado %q", header, got, want)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+	"url-shortener/internal/models"
+)
+
+const (
+	defaultStatsRange = 7 * 24 * time.Hour
+	maxStatsRange     = 90 * 24 * time.Hour
+	topEntriesLimit   = 10
+)
+
+// buildStats agrega os cliques do intervalo [from, to) em histogramas e
+// rankings. Só o dono (owner) recebe o link completo e os destinos anteriores.
+func buildStats(link *models.URL, events []*models.ClickEvent, from, to time.Time, owner bool) *models.URLStats {
+	stats := &models.URLStats{
+		URL:         link.StatsView(),
+		From:        from,
+		To:          to,
+		TotalClicks: len(events),
+		Hourly:      emptyBuckets(from, to, time.Hour),
+		Daily:       emptyBuckets(from, to, 24*time.Hour),
+	}
+
+	visitors := make(map[string]struct{})
+	referrers := make(map[string]int)
+	countries := make(map[string]int)
+	devices := make(map[string]int)
+	rules := make(map[string]int)
+	versions := make(map[int]int)
+
+	for _, event := range events {
+		visitors[event.VisitorID] = struct{}{}
+		referrers[referrerHost(event.Referrer)]++
+		devices[event.Device]++
+
+		country := event.Country
+		if country == "" {
+			country = "unknown"
+		}
+		countries[country]++
+
+		rule := event.Rule
+		switch {
+		case rule == "":
+			rule = "default"
+		case event.Variant != "":
+			rule += "/" + event.Variant
+		}
+		rules[rule]++
+		versions[max(event.Version, 1)]++
+
+		addToBucket(stats.Hourly, event.Timestamp, time.Hour)
+		addToBucket(stats.Daily, event.Timestamp, 24*time.Hour)
+	}
+
+	stats.UniqueVisitors = len(visitors)
+	stats.TopReferrers = topEntries(referrers, topEntriesLimit)
+	stats.Countries = topEntries(countries, 0)
+	stats.Devices = topEntries(devices, 0)
+	stats.Rules = topEntries(rules, 0)
+	stats.Versions = versionCounts(link, versions, owner)
+	if owner {
+		stats.URL = link.Public()
+	}
+	return stats
+}
+
+// versionCounts lista todas as versões do destino, em ordem, com os cliques
+// do intervalo. Os destinos anteriores só aparecem para o dono.
+func versionCounts(link *models.URL, counts map[int]int, owner bool) []models.VersionCount {
+	history := link.Versions()
+	current := link.DestinationVersion()
+	entries := make([]models.VersionCount, 0, len(history))
+	for _, version := range history {
+		entry := models.VersionCount{
+			Version: version.Version,
+			Since:   version.ChangedAt,
+			Clicks:  counts[version.Version],
+		}
+		if owner || version.Version == current {
+			entry.Destination = version.URL
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// emptyBuckets cria os intervalos do histograma alinhados em UTC
+func emptyBuckets(from, to time.Time, size time.Duration) []models.TimeBucket {
+	buckets := []models.TimeBucket{}
+	for start := from.UTC().Truncate(size); start.Before(to); start = start.Add(size) {
+		buckets = append(buckets, models.TimeBucket{Start: start})
+	}
+	return buckets
+}
+
+func addToBucket(buckets []models.TimeBucket, ts time.Time, size time.Duration) {
+	if len(buckets) == 0 {
+		return
+	}
+	i := int(ts.UTC().Truncate(size).Sub(buckets[0].Start) / size)
+	if i >= 0 && i < len(buckets) {
+		buckets[i].Clicks++
+	}
+}
+
+// referrerHost reduz o referrer ao host; acessos sem referrer são "direct"
+func referrerHost(referrer string) string {
+	if referrer == "" {
+		return "direct"
+	}
+	u, err := url.Parse(referrer)
+	if err != nil || u.Host == "" {
+		return referrer
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// topEntries ordena as contagens de forma decrescente; limit 0 retorna todas
+func topEntries(counts map[string]int, limit int) []models.CountEntry {
+	entries := make([]models.CountEntry, 0, len(counts))
+	for value, clicks := range counts {
+		entries = append(entries, models.CountEntry{Value: value, Clicks: clicks})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Clicks != entries[j].Clicks {
+			return entries[i].Clicks > entries[j].Clicks
+		}
+		return entries[i].Value < entries[j].Value
+	})
+
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries
+}
@@ -1,75 +1,692 @@
 package service
 
 import (
-	"crypto/rand"
-	"encoding/base64"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
 	"time"
+	"url-shortener/internal/metrics"
 	"url-shortener/internal/models"
 	"url-shortener/internal/repository"
 )
 
+const (
+	defaultCodeLength = 6
+	minCodeLength     = 4
+	maxCodeLength     = 16
+	minAliasLength    = 3
+	maxAliasLength    = 32
+)
+
+var (
+	ErrURLRequired       = errors.New("URL é obrigatória")
+	ErrInvalidAlias      = fmt.Errorf("alias inválido: use de %d a %d letras, números, '-' ou '_'", minAliasLength, maxAliasLength)
+	ErrReservedAlias     = errors.New("alias reservado")
+	ErrAliasInUse        = errors.New("alias já está em uso")
+	ErrInvalidCodeLength = fmt.Errorf("code_length deve estar entre %d e %d", minCodeLength, maxCodeLength)
+	ErrInvalidExpiration = errors.New("expires_at deve estar no futuro")
+	ErrInvalidMaxClicks  = errors.New("max_clicks não pode ser negativo")
+	ErrURLExpired        = errors.New("URL expirada")
+	ErrInvalidRange      = fmt.Errorf("intervalo inválido: from deve ser anterior a to e o período não pode exceder %d dias", int(maxStatsRange.Hours()/24))
+)
+
+// Palavras que colidem com rotas da API e não podem ser usadas como código
+var reservedWords = map[string]bool{
+	"shorten":  true,
+	"stats":    true,
+	"links":    true,
+	"accounts": true,
+	"api":      true,
+	"admin":    true,
+	"static":   true,
+	"qr":       true,
+	"metrics":  true,
+	"healthz":  true,
+	"readyz":   true,
+}
+
 type URLService struct {
-	store  *repository.MemoryStore
-	domain string
+	store     repository.Store
+	domain    string
+	createMu  sync.Mutex // torna atômicas a verificação de colisão e o Save
+	clickMu   sync.Mutex // serializa os cliques de links com limite de cliques
+	locator   IPLocator
+	validator *URLValidator
+	clicks    *ClickRecorder
+	unlocks   *unlockGuard // tentativas de senha por link
+
+	codeLength int           // tamanho padrão dos códigos gerados
+	codes      CodeGenerator // estratégia de geração dos códigos
+
+	primaryHost string            // host do domínio principal
+	domains     map[string]string // host do domínio adicional -> URL base
 }
 
-func NewURLService(store *repository.MemoryStore, domain string) *URLService {
+func NewURLService(store repository.Store, domain string) *URLService {
+	primaryHost, _ := domainHost(domain)
+
 	return &URLService{
-		store:  store,
-		domain: domain,
+		store:     store,
+		domain:    domain,
+		locator:   NoopLocator{},
+		validator: NewURLValidator(domain),
+		unlocks:   newUnlockGuard(),
+
+		codeLength: defaultCodeLength,
+		codes:      NewRandomGenerator(DefaultCodeAlphabet),
+
+		primaryHost: primaryHost,
+		domains:     make(map[string]string),
 	}
 }
 
-func (s *URLService) CreateShortURL(originalURL string) (*models.CreateURLResponse, error) {
-	// Gerar código curto único
-	shortCode := generateShortCode(6)
-	
-	// Verificar se já existe (colisão)
-	for {
-		_, err := s.store.FindByShortCode(shortCode)
-		if err != nil {
-			break // Não existe, podemos usar
+// SetCodeLength define o tamanho padrão dos códigos gerados
+func (s *URLService) SetCodeLength(length int) {
+	s.codeLength = length
+}
+
+// SetClickRecorder grava os cliques em lote pelo pipeline (links sem limite
+// de cliques; os demais continuam síncronos)
+func (s *URLService) SetClickRecorder(recorder *ClickRecorder) {
+	s.clicks = recorder
+}
+
+// Validator expõe a validação de destinos para configuração
+func (s *URLService) Validator() *URLValidator {
+	return s.validator
+}
+
+// SetBlocklist ativa a lista de domínios bloqueados na criação e no
+// redirecionamento
+func (s *URLService) SetBlocklist(blocklist *BlocklistService) {
+	s.validator.blocklist = blocklist
+}
+
+// SetIPLocator define como o país de cada clique é resolvido
+func (s *URLService) SetIPLocator(locator IPLocator) {
+	s.locator = locator
+}
+
+// CreateShortURL cria o link; ownerID vazio cria um link anônimo
+func (s *URLService) CreateShortURL(req models.CreateURLRequest, ownerID string) (*models.CreateURLResponse, error) {
+	destination, err := s.validator.Validate(req.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	codeLength := req.CodeLength
+	if codeLength == 0 {
+		codeLength = s.codeLength
+	}
+	if codeLength < minCodeLength || codeLength > maxCodeLength {
+		return nil, ErrInvalidCodeLength
+	}
+
+	if req.Alias != "" {
+		if err := validateAlias(req.Alias); err != nil {
+			return nil, err
 		}
-		shortCode = generateShortCode(6)
 	}
-	
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, ErrInvalidExpiration
+	}
+	if req.MaxClicks < 0 {
+		return nil, ErrInvalidMaxClicks
+	}
+	domain, err := s.resolveDomain(req.Domain)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateRedirectType(req.RedirectType); err != nil {
+		return nil, err
+	}
+	if req.OpenGraph, err = normalizeOpenGraph(req.OpenGraph); err != nil {
+		return nil, err
+	}
+	if req.UTM, err = normalizeUTM(req.UTM); err != nil {
+		return nil, err
+	}
+	if req.Rules, err = normalizeRules(req.Rules, s.validator.Validate); err != nil {
+		return nil, err
+	}
+	var passwordHash string
+	if req.Password != "" {
+		// Sem dono ninguém poderia ver as estatísticas nem alterar a senha
+		if ownerID == "" {
+			return nil, ErrPasswordRequiresAccount
+		}
+		if passwordHash, err = hashPassword(req.Password); err != nil {
+			return nil, err
+		}
+	}
+
+	dedupeKey := normalizeDestination(destination)
+
+	s.createMu.Lock()
+	defer s.createMu.Unlock()
+
+	// Com alias o usuário pediu um código específico, então não há
+	// deduplicação; links com senha também nunca são reaproveitados
+	if req.Dedupe && req.Alias == "" && passwordHash == "" {
+		if existing := s.findDuplicate(ownerID, domain, dedupeKey, req); existing != nil {
+			resp := s.createResponse(existing)
+			resp.Existing = true
+			return resp, nil
+		}
+	}
+
+	// Os códigos são únicos por domínio
+	shortCode := req.Alias
+	var sequence uint64
+	if shortCode != "" {
+		if s.keyTaken(models.LinkKey(domain, shortCode)) {
+			return nil, ErrAliasInUse
+		}
+	} else if shortCode, sequence, err = s.nextCode(domain, codeLength); err != nil {
+		return nil, err
+	}
+
 	url := &models.URL{
-		ID:          generateShortCode(8),
-		OriginalURL: originalURL,
-		ShortCode:   shortCode,
-		CreatedAt:   time.Now(),
-		Clicks:      0,
+		ID:           newID(8),
+		OriginalURL:  destination,
+		ShortCode:    shortCode,
+		CreatedAt:    time.Now(),
+		Clicks:       0,
+		ExpiresAt:    req.ExpiresAt,
+		MaxClicks:    req.MaxClicks,
+		OwnerID:      ownerID,
+		Domain:       domain,
+		DedupeKey:    dedupeKey,
+		Sequence:     sequence,
+		RedirectType: req.RedirectType,
+		OpenGraph:    req.OpenGraph,
+		UTM:          req.UTM,
+		ForwardQuery: req.ForwardQuery,
+		Rules:        req.Rules,
+		PasswordHash: passwordHash,
 	}
-	
+
 	if err := s.store.Save(url); err != nil {
 		return nil, err
 	}
-	
+	metrics.LinksCreated.Inc()
+
+	return s.createResponse(url), nil
+}
+
+func (s *URLService) createResponse(url *models.URL) *models.CreateURLResponse {
 	return &models.CreateURLResponse{
-		ShortURL:    fmt.Sprintf("%s/%s", s.domain, shortCode),
-		OriginalURL: originalURL,
+		ShortURL:     s.linkURL(url),
+		Domain:       url.Domain,
+		QRURL:        fmt.Sprintf("%s/qr/%s", s.baseURL(url.Domain), url.ShortCode),
+		OriginalURL:  url.OriginalURL,
+		ExpiresAt:    url.ExpiresAt,
+		MaxClicks:    url.MaxClicks,
+		RedirectType: redirectStatus(url.RedirectType),
+		UTM:          url.UTM,
+		ForwardQuery: url.ForwardQuery,
+		Rules:        url.Rules,
+		Protected:    url.IsProtected(),
+	}
+}
+
+// GetOriginalURL resolve o link, aplicando as regras de roteamento, e
+// registra o clique. Crawlers de redes sociais recebem os metadados Open
+// Graph do link (quando houver) sem contar clique. Links com senha retornam
+// ErrPasswordRequired e são resolvidos por UnlockURL.
+func (s *URLService) GetOriginalURL(shortCode string, info models.RequestInfo) (*models.Redirect, error) {
+	url, err := s.findActive(shortCode)
+	if err != nil {
+		return nil, err
+	}
+	if url.IsProtected() {
+		return nil, ErrPasswordRequired
+	}
+
+	if url.OpenGraph != nil && isSocialCrawler(info.UserAgent) {
+		redirect, err := s.newRedirect(url, url.OriginalURL, info.Query)
+		if err != nil {
+			return nil, err
+		}
+		redirect.OpenGraph = url.OpenGraph
+		return redirect, nil
+	}
+
+	return s.follow(url, shortCode, info)
+}
+
+// follow aplica as regras de roteamento e registra o clique
+func (s *URLService) follow(url *models.URL, shortCode string, info models.RequestInfo) (*models.Redirect, error) {
+	event := s.newClickEvent(url, info)
+
+	redirect, err := s.route(url, info, event)
+	if err != nil {
+		return nil, err
+	}
+
+	if url.MaxClicks > 0 {
+		// Com limite de cliques a contagem é síncrona para o limite ser exato
+		s.clickMu.Lock()
+		defer s.clickMu.Unlock()
+
+		// Relê o link: o contador pode ter mudado desde findActive
+		url, err = s.find(shortCode)
+		if err != nil {
+			return nil, err
+		}
+		if url.IsExpired(event.Timestamp) {
+			return nil, ErrURLExpired
+		}
+		if err := s.store.RecordClick(event); err != nil {
+			return nil, err
+		}
+		return redirect, nil
+	}
+
+	// Registrar clique: em lote pelo pipeline ou, sem ele, diretamente
+	if s.clicks != nil {
+		s.clicks.Record(event)
+	} else if err := s.store.RecordClick(event); err != nil {
+		return nil, err
+	}
+
+	return redirect, nil
+}
+
+// GetPreview resolve o link sem registrar clique, para a página de
+// pré-visualização. O destino é o mesmo que o visitante receberia, sem a
+// query da URL curta.
+func (s *URLService) GetPreview(shortCode string, info models.RequestInfo) (*models.Redirect, error) {
+	url, err := s.findActive(shortCode)
+	if err != nil {
+		return nil, err
+	}
+	if url.IsProtected() {
+		return nil, ErrPasswordRequired
+	}
+
+	info.Query = ""
+	redirect, err := s.route(url, info, s.newClickEvent(url, info))
+	if err != nil {
+		return nil, err
+	}
+	redirect.OpenGraph = url.OpenGraph
+	return redirect, nil
+}
+
+// route escolhe o destino pelas regras do link e anota no evento a regra
+// aplicada
+func (s *URLService) route(url *models.URL, info models.RequestInfo, event *models.ClickEvent) (*models.Redirect, error) {
+	destination := url.OriginalURL
+
+	visitor := visitorContext{
+		Device:    event.Device,
+		OS:        osName(info.UserAgent),
+		Language:  preferredLanguage(info.AcceptLanguage),
+		Country:   event.Country,
+		Time:      event.Timestamp,
+		VisitorID: event.VisitorID,
+	}
+	if rule, ruleDestination, variant := matchRule(url.Rules, visitor); rule != nil {
+		if err := s.validator.CheckBlocklist(ruleDestination); err != nil {
+			return nil, err
+		}
+		destination = ruleDestination
+		event.Rule, event.Variant = rule.ID, variant
+	}
+
+	return s.newRedirect(url, destination, info.Query)
+}
+
+func (s *URLService) newRedirect(url *models.URL, destination, query string) (*models.Redirect, error) {
+	destination, err := buildDestination(url, destination, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Redirect{
+		Destination: destination,
+		Status:      redirectStatus(url.RedirectType),
+		ShortURL:    s.linkURL(url),
 	}, nil
 }
 
-func (s *URLService) GetOriginalURL(shortCode string) (string, error) {
+// find busca o link; links expirados já removidos pelo sweeper retornam
+// ErrURLExpired
+func (s *URLService) find(shortCode string) (*models.URL, error) {
 	url, err := s.store.FindByShortCode(shortCode)
+	if errors.Is(err, repository.ErrExpired) {
+		return nil, ErrURLExpired
+	}
+	return url, err
+}
+
+// keyTaken indica se a chave pertence a um link, inclusive a um link
+// expirado já removido: esses códigos nunca são reutilizados
+func (s *URLService) keyTaken(key string) bool {
+	_, err := s.store.FindByShortCode(key)
+	return !errors.Is(err, repository.ErrNotFound)
+}
+
+// findActive busca o link e verifica bloqueio e expiração
+func (s *URLService) findActive(shortCode string) (*models.URL, error) {
+	url, err := s.find(shortCode)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.validator.CheckBlocklist(url.OriginalURL); err != nil {
+		return nil, err
+	}
+	if url.IsExpired(time.Now()) {
+		return nil, ErrURLExpired
+	}
+	return url, nil
+}
+
+// ShortURL retorna a URL curta de um link existente e ainda ativo
+func (s *URLService) ShortURL(shortCode string) (string, error) {
+	url, err := s.find(shortCode)
 	if err != nil {
 		return "", err
 	}
-	
-	// Incrementar contador de cliques (assíncrono)
-	go s.store.IncrementClicks(shortCode)
-	
-	return url.OriginalURL, nil
+	if url.IsExpired(time.Now()) {
+		return "", ErrURLExpired
+	}
+	return s.linkURL(url), nil
 }
 
-func (s *URLService) GetURLStats(shortCode string) (*models.URL, error) {
-	return s.store.FindByShortCode(shortCode)
+// GetURLStats agrega os cliques do link no intervalo [from, to). Valores
+// zero usam os últimos 7 dias. As estatísticas de links com senha só são
+// visíveis para o dono (ownerID da chave de API da requisição); para os
+// demais a resposta traz apenas os campos públicos do link.
+func (s *URLService) GetURLStats(shortCode, ownerID string, from, to time.Time) (*models.URLStats, error) {
+	url, err := s.find(shortCode)
+	if err != nil {
+		return nil, err
+	}
+	owner := ownerID != "" && url.OwnerID == ownerID
+	if url.IsProtected() && !owner {
+		return nil, ErrStatsForbidden
+	}
+
+	if to.IsZero() {
+		to = time.Now()
+	}
+	if from.IsZero() {
+		from = to.Add(-defaultStatsRange)
+	}
+	if !from.Before(to) || to.Sub(from) > maxStatsRange {
+		return nil, ErrInvalidRange
+	}
+
+	events := s.store.GetClicks(shortCode, from, to)
+	return buildStats(url, events, from, to, owner), nil
 }
 
-func generateShortCode(length int) string {
-	b := make([]byte, length)
-	rand.Read(b)
-	return base64.URLEncoding.EncodeToString(b)[:length]
+// ListLinks retorna os links do dono, do mais recente para o mais antigo
+func (s *URLService) ListLinks(ownerID string, limit, offset int) *models.ListURLsResponse {
+	return s.listOwned(ownerID, limit, offset, func(url *models.URL) bool { return true })
+}
+
+// BrokenLinks lista os links do dono cuja última verificação do destino
+// falhou, do mais recente para o mais antigo
+func (s *URLService) BrokenLinks(ownerID string, limit, offset int) *models.ListURLsResponse {
+	return s.listOwned(ownerID, limit, offset, func(url *models.URL) bool { return url.Health.Broken() })
+}
+
+func (s *URLService) listOwned(ownerID string, limit, offset int, keep func(url *models.URL) bool) *models.ListURLsResponse {
+	var links []*models.URL
+	for _, url := range s.store.GetAll() {
+		if url.OwnerID == ownerID && keep(url) {
+			links = append(links, url)
+		}
+	}
+
+	sort.Slice(links, func(i, j int) bool {
+		return links[i].CreatedAt.After(links[j].CreatedAt)
+	})
+
+	total := len(links)
+	if offset > total {
+		offset = total
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+
+	page := make([]*models.URL, 0, end-offset)
+	for _, url := range links[offset:end] {
+		page = append(page, url.Public())
+	}
+
+	return &models.ListURLsResponse{
+		Links:  page,
+		Total:  total,
+		Limit:  limit,
+		Offset: offset,
+	}
+}
+
+// ExportLinks percorre os links do dono, do mais antigo para o mais
+// recente, chamando fn com os dados e estatísticas de cada um
+func (s *URLService) ExportLinks(ownerID string, fn func(row models.LinkExport) error) error {
+	var links []*models.URL
+	for _, url := range s.store.GetAll() {
+		if url.OwnerID == ownerID {
+			links = append(links, url)
+		}
+	}
+
+	sort.Slice(links, func(i, j int) bool {
+		return links[i].CreatedAt.Before(links[j].CreatedAt)
+	})
+
+	now := time.Now()
+	for _, url := range links {
+		row := models.LinkExport{
+			ShortCode:   url.ShortCode,
+			ShortURL:    s.linkURL(url),
+			OriginalURL: url.OriginalURL,
+			CreatedAt:   url.CreatedAt,
+			ExpiresAt:   url.ExpiresAt,
+			MaxClicks:   url.MaxClicks,
+			Clicks:      url.Clicks,
+			Domain:      url.Domain,
+		}
+
+		events := s.store.GetClicks(url.Key(), time.Time{}, now.Add(time.Second))
+		visitors := make(map[string]struct{})
+		for _, event := range events {
+			visitors[event.VisitorID] = struct{}{}
+		}
+		row.UniqueVisitors = len(visitors)
+		if len(events) > 0 {
+			last := events[len(events)-1].Timestamp
+			row.LastClickAt = &last
+		}
+
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdateLink altera um link do dono. Um novo destino mantém o código e
+// entra no histórico de versões com a conta que fez a alteração.
+func (s *URLService) UpdateLink(ownerID, shortCode string, req models.UpdateURLRequest) (*models.URL, error) {
+	var destination string
+	if req.URL != nil {
+		var err error
+		if destination, err = s.validator.Validate(*req.URL); err != nil {
+			return nil, err
+		}
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, ErrInvalidExpiration
+	}
+	if req.MaxClicks != nil && *req.MaxClicks < 0 {
+		return nil, ErrInvalidMaxClicks
+	}
+	if req.RedirectType != nil {
+		if err := validateRedirectType(*req.RedirectType); err != nil {
+			return nil, err
+		}
+	}
+	openGraph, err := normalizeOpenGraph(req.OpenGraph)
+	if err != nil {
+		return nil, err
+	}
+	utm, err := normalizeUTM(req.UTM)
+	if err != nil {
+		return nil, err
+	}
+	var rules []models.RoutingRule
+	if req.Rules != nil {
+		if rules, err = normalizeRules(*req.Rules, s.validator.Validate); err != nil {
+			return nil, err
+		}
+	}
+	var passwordHash string
+	if req.Password != nil && *req.Password != "" {
+		if passwordHash, err = hashPassword(*req.Password); err != nil {
+			return nil, err
+		}
+	}
+
+	url, err := s.store.Update(shortCode, func(url *models.URL) error {
+		if url.OwnerID == "" || url.OwnerID != ownerID {
+			return repository.ErrNotFound
+		}
+
+		if destination != "" && destination != url.OriginalURL {
+			changeDestination(url, destination, ownerID, time.Now())
+		}
+
+		if req.RemoveExpiration {
+			url.ExpiresAt = nil
+		}
+		if req.ExpiresAt != nil {
+			url.ExpiresAt = req.ExpiresAt
+		}
+		if req.MaxClicks != nil {
+			url.MaxClicks = *req.MaxClicks
+		}
+		if req.RedirectType != nil {
+			url.RedirectType = *req.RedirectType
+		}
+		if req.RemoveOpenGraph {
+			url.OpenGraph = nil
+		}
+		if openGraph != nil {
+			url.OpenGraph = openGraph
+		}
+		if req.RemoveUTM {
+			url.UTM = nil
+		}
+		if utm != nil {
+			url.UTM = utm
+		}
+		if req.ForwardQuery != nil {
+			url.ForwardQuery = *req.ForwardQuery
+		}
+		if req.Rules != nil {
+			url.Rules = rules
+		}
+		if req.Password != nil {
+			url.PasswordHash = passwordHash
+		}
+		return nil
+	})
+	if errors.Is(err, repository.ErrExpired) {
+		return nil, ErrURLExpired
+	}
+	if err != nil {
+		return nil, err
+	}
+	if req.Password != nil {
+		s.unlocks.reset(shortCode)
+	}
+	return url.Public(), nil
+}
+
+// DeleteLink remove um link do dono
+func (s *URLService) DeleteLink(ownerID, shortCode string) error {
+	if _, err := s.findOwned(ownerID, shortCode); err != nil {
+		return err
+	}
+	return s.store.Delete(shortCode)
+}
+
+// findOwned busca o link e trata links de outros donos como inexistentes
+func (s *URLService) findOwned(ownerID, shortCode string) (*models.URL, error) {
+	url, err := s.find(shortCode)
+	if err != nil {
+		return nil, err
+	}
+	if url.OwnerID == "" || url.OwnerID != ownerID {
+		return nil, repository.ErrNotFound
+	}
+	return url, nil
+}
+
+func (s *URLService) newClickEvent(url *models.URL, info models.RequestInfo) *models.ClickEvent {
+	visitor := sha256.Sum256([]byte(info.IP + "|" + info.UserAgent))
+
+	return &models.ClickEvent{
+		ShortCode: url.ShortCode,
+		Domain:    url.Domain,
+		Version:   url.DestinationVersion(),
+		Timestamp: time.Now(),
+		Referrer:  info.Referrer,
+		UserAgent: info.UserAgent,
+		Country:   s.locator.Country(info.IP),
+		Device:    deviceClass(info.UserAgent),
+		VisitorID: hex.EncodeToString(visitor[:8]),
+	}
+}
+
+// validateAlias verifica tamanho, caracteres permitidos e palavras reservadas
+func validateAlias(alias string) error {
+	if len(alias) < minAliasLength || len(alias) > maxAliasLength {
+		return ErrInvalidAlias
+	}
+
+	for _, c := range alias {
+		isAlnum := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+		if !isAlnum && c != '-' && c != '_' {
+			return ErrInvalidAlias
+		}
+	}
+
+	if reservedWords[strings.ToLower(alias)] {
+		return ErrReservedAlias
+	}
+
+	return nil
+}
+
+// changeDestination troca o destino do link e registra a nova versão
+func changeDestination(url *models.URL, destination, actor string, now time.Time) {
+	version := url.DestinationVersion() + 1
+	// Cópia: o link salvo não pode ser alterado no lugar
+	history := append(append([]models.DestinationVersion{}, url.Versions()...), models.DestinationVersion{
+		Version:   version,
+		URL:       destination,
+		ChangedAt: now,
+		ChangedBy: actor,
+	})
+
+	url.OriginalURL = destination
+	url.DedupeKey = normalizeDestination(destination)
+	url.Version = version
+	url.History = history
+	url.Health = nil // o resultado da verificação era do destino anterior
 }
@@ -0,0 +1,105 @@
+package service
+
+import "strings"
+
+const (
+	DeviceDesktop = "desktop"
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceBot     = "bot"
+	DeviceUnknown = "unknown"
+)
+
+const (
+	OSiOS      = "ios"
+	OSAndroid  = "android"
+	OSWindows  = "windows"
+	OSMacOS    = "macos"
+	OSChromeOS = "chromeos"
+	OSLinux    = "linux"
+	OSUnknown  = "unknown"
+)
+
+var botMarkers = []string{"bot", "crawler", "spider", "slurp", "curl", "wget", "python-requests", "go-http-client", "headless"}
+
+// Crawlers que geram a prévia de links compartilhados em redes sociais e
+// mensageiros; nem todos se identificam como "bot"
+var socialCrawlerMarkers = []string{
+	"facebookexternalhit", "facebot", "twitterbot", "linkedinbot", "slackbot",
+	"discordbot", "telegrambot", "whatsapp", "pinterest", "redditbot",
+	"skypeuripreview", "embedly", "vkshare", "mastodon",
+}
+
+// isSocialCrawler indica se o User-Agent é de um gerador de prévias de links
+func isSocialCrawler(userAgent string) bool {
+	ua := strings.ToLower(userAgent)
+	for _, marker := range socialCrawlerMarkers {
+		if strings.Contains(ua, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// deviceClass classifica o dispositivo a partir do User-Agent
+func deviceClass(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+	if ua == "" {
+		return DeviceUnknown
+	}
+
+	for _, marker := range botMarkers {
+		if strings.Contains(ua, marker) {
+			return DeviceBot
+		}
+	}
+	if isSocialCrawler(ua) {
+		return DeviceBot
+	}
+
+	switch {
+	case strings.Contains(ua, "ipad"),
+		strings.Contains(ua, "tablet"),
+		strings.Contains(ua, "android") && !strings.Contains(ua, "mobile"):
+		return DeviceTablet
+	case strings.Contains(ua, "mobi"),
+		strings.Contains(ua, "iphone"),
+		strings.Contains(ua, "ipod"),
+		strings.Contains(ua, "windows phone"):
+		return DeviceMobile
+	case strings.Contains(ua, "windows"),
+		strings.Contains(ua, "macintosh"),
+		strings.Contains(ua, "x11"),
+		strings.Contains(ua, "linux"),
+		strings.Contains(ua, "cros"):
+		return DeviceDesktop
+	default:
+		return DeviceUnknown
+	}
+}
+
+// osName identifica o sistema operacional a partir do User-Agent. A ordem
+// importa: Android também contém "linux" e o iOS contém "mac os x".
+func osName(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+	switch {
+	case strings.Contains(ua, "iphone"),
+		strings.Contains(ua, "ipad"),
+		strings.Contains(ua, "ipod"):
+		return OSiOS
+	case strings.Contains(ua, "android"):
+		return OSAndroid
+	case strings.Contains(ua, "windows"):
+		return OSWindows
+	case strings.Contains(ua, "cros"):
+		return OSChromeOS
+	case strings.Contains(ua, "macintosh"),
+		strings.Contains(ua, "mac os x"):
+		return OSMacOS
+	case strings.Contains(ua, "linux"),
+		strings.Contains(ua, "x11"):
+		return OSLinux
+	default:
+		return OSUnknown
+	}
+}
@@ -0,0 +1,244 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidURL       = errors.New("URL inválida")
+	ErrSchemeNotAllowed = errors.New("protocolo não permitido: use http ou https")
+	ErrCredentialsInURL = errors.New("URLs com usuário e senha não são permitidas")
+	ErrPrivateHost      = errors.New("destinos em rede local ou privada não são permitidos")
+	ErrSelfReference    = errors.New("a URL não pode apontar para o próprio encurtador")
+	ErrBlockedDomain    = errors.New("domínio bloqueado")
+)
+
+const resolveTimeout = 2 * time.Second
+
+// Faixas reservadas que não são cobertas pelos métodos de net.IP
+var reservedNetworks = mustParseCIDRs(
+	"0.0.0.0/8",       // "esta" rede
+	"100.64.0.0/10",   // CGNAT
+	"192.0.0.0/24",    // atribuições de protocolo IETF
+	"192.0.2.0/24",    // documentação
+	"198.18.0.0/15",   // testes de desempenho
+	"198.51.100.0/24", // documentação
+	"203.0.113.0/24",  // documentação
+	"240.0.0.0/4",     // reservado e broadcast
+	"64:ff9b:1::/48",  // tradução IPv4/IPv6 local
+	"100::/64",        // descarte
+	"2001::/32",       // Teredo
+	"2001:db8::/32",   // documentação
+	"2002::/16",       // 6to4 (pode embutir IPv4 privado)
+	"fec0::/10",       // site-local (obsoleto)
+)
+
+// Sufixos de nomes que só resolvem em redes locais
+var localSuffixes = []string{"localhost", ".local", ".localdomain", ".internal", ".intranet", ".lan", ".home.arpa"}
+
+// Resolver resolve os endereços de um host
+type Resolver func(ctx context.Context, host string) ([]net.IPAddr, error)
+
+// URLValidator é a cadeia de validação dos destinos: protocolo, host,
+// redes privadas, o próprio encurtador e a lista de bloqueio
+type URLValidator struct {
+	allowedSchemes map[string]bool
+	selfHosts      map[string]bool
+	blocklist      *BlocklistService
+	resolver       Resolver
+}
+
+func NewURLValidator(domain string) *URLValidator {
+	v := &URLValidator{
+		allowedSchemes: map[string]bool{"http": true, "https": true},
+		selfHosts:      make(map[string]bool),
+		resolver:       net.DefaultResolver.LookupIPAddr,
+	}
+	v.AddSelfHost(domain)
+	return v
+}
+
+// AddSelfHost registra um domínio do próprio encurtador (aceita URL ou host)
+func (v *URLValidator) AddSelfHost(domain string) {
+	host := domain
+	if u, err := url.Parse(domain); err == nil && u.Host != "" {
+		host = u.Hostname()
+	}
+	if host = normalizeHost(host); host != "" {
+		v.selfHosts[host] = true
+	}
+}
+
+// SetResolver define o resolvedor DNS; nil desativa a verificação dos
+// endereços resolvidos
+func (v *URLValidator) SetResolver(resolver Resolver) {
+	v.resolver = resolver
+}
+
+// Validate normaliza a URL (adicionando https:// quando não há protocolo) e
+// aplica todas as verificações, retornando a URL que deve ser armazenada
+func (v *URLValidator) Validate(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", ErrURLRequired
+	}
+
+	u, err := url.Parse(withDefaultScheme(raw))
+	if err != nil {
+		return "", ErrInvalidURL
+	}
+
+	if !v.allowedSchemes[strings.ToLower(u.Scheme)] {
+		return "", ErrSchemeNotAllowed
+	}
+	if u.Opaque != "" || u.Host == "" {
+		return "", ErrInvalidURL
+	}
+	if u.User != nil {
+		return "", ErrCredentialsInURL
+	}
+
+	host := normalizeHost(u.Hostname())
+	if host == "" {
+		return "", ErrInvalidURL
+	}
+
+	if v.selfHosts[host] {
+		return "", ErrSelfReference
+	}
+	if err := v.checkBlocklist(host); err != nil {
+		return "", err
+	}
+	if err := v.checkHost(host); err != nil {
+		return "", err
+	}
+
+	return u.String(), nil
+}
+
+// CheckBlocklist verifica se o host da URL está bloqueado; usado também no
+// redirecionamento para derrubar links já existentes
+func (v *URLValidator) CheckBlocklist(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ErrInvalidURL
+	}
+	return v.checkBlocklist(normalizeHost(u.Hostname()))
+}
+
+func (v *URLValidator) checkBlocklist(host string) error {
+	if v.blocklist != nil && v.blocklist.Matches(host) {
+		return ErrBlockedDomain
+	}
+	return nil
+}
+
+// checkHost barra IPs e nomes locais, privados ou reservados (SSRF)
+func (v *URLValidator) checkHost(host string) error {
+	if ip := net.ParseIP(host); ip != nil {
+		if isPrivateIP(ip) {
+			return ErrPrivateHost
+		}
+		return nil
+	}
+
+	for _, suffix := range localSuffixes {
+		if host == strings.TrimPrefix(suffix, ".") || strings.HasSuffix(host, suffix) {
+			return ErrPrivateHost
+		}
+	}
+
+	// Nenhum TLD é numérico: hosts como "2130706433" ou "0x7f.1" são
+	// formas alternativas de IP interpretadas por alguns clientes
+	labels := strings.Split(host, ".")
+	if tld := labels[len(labels)-1]; tld == "" || isNumericLabel(tld) {
+		return ErrInvalidURL
+	}
+
+	if v.resolver == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+
+	// Falhas de DNS não bloqueiam a criação; só endereços privados conhecidos
+	addrs, err := v.resolver(ctx, host)
+	if err != nil {
+		return nil
+	}
+	for _, addr := range addrs {
+		if isPrivateIP(addr.IP) {
+			return ErrPrivateHost
+		}
+	}
+	return nil
+}
+
+func isPrivateIP(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return true
+	}
+
+	for _, network := range reservedNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func isNumericLabel(label string) bool {
+	label = strings.TrimPrefix(label, "0x")
+	for _, c := range label {
+		isHex := (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f')
+		if !isHex {
+			return false
+		}
+	}
+	return strings.ContainsAny(label, "0123456789")
+}
+
+// withDefaultScheme adiciona https:// quando a URL não tem protocolo.
+// Protocolos explícitos como "javascript:" são mantidos para serem
+// rejeitados pela validação; "host:porta" não é confundido com protocolo.
+func withDefaultScheme(raw string) string {
+	if strings.Contains(raw, "://") {
+		return raw
+	}
+
+	if i := strings.Index(raw, ":"); i > 0 {
+		rest := raw[i+1:]
+		if rest == "" || rest[0] < '0' || rest[0] > '9' {
+			return raw
+		}
+	}
+	return "https://" + raw
+}
+
+func normalizeHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+}
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
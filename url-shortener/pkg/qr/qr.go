@@ -0,0 +1,153 @@
+// Package qr gera QR codes em PNG ou SVG com tamanho, nível de correção de
+// erros e margem configuráveis
+package qr
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+const (
+	MinSize   = 64
+	MaxSize   = 2048
+	MaxMargin = 16
+
+	DefaultSize   = 256
+	DefaultLevel  = "M"
+	DefaultMargin = 4 // zona de silêncio recomendada pela especificação
+)
+
+var (
+	ErrInvalidSize   = fmt.Errorf("size deve estar entre %d e %d", MinSize, MaxSize)
+	ErrInvalidLevel  = errors.New("level deve ser L, M, Q ou H")
+	ErrInvalidMargin = fmt.Errorf("margin deve estar entre 0 e %d", MaxMargin)
+)
+
+// Níveis de correção de erros: L ~7%, M ~15%, Q ~25%, H ~30%
+var levels = map[string]qrcode.RecoveryLevel{
+	"L": qrcode.Low,
+	"M": qrcode.Medium,
+	"Q": qrcode.High,
+	"H": qrcode.Highest,
+}
+
+type Options struct {
+	Size   int    // largura e altura da imagem em pixels
+	Level  string // L, M, Q ou H
+	Margin int    // margem em módulos
+}
+
+func DefaultOptions() Options {
+	return Options{Size: DefaultSize, Level: DefaultLevel, Margin: DefaultMargin}
+}
+
+func (o Options) Validate() error {
+	if o.Size < MinSize || o.Size > MaxSize {
+		return ErrInvalidSize
+	}
+	if _, ok := levels[strings.ToUpper(o.Level)]; !ok {
+		return ErrInvalidLevel
+	}
+	if o.Margin < 0 || o.Margin > MaxMargin {
+		return ErrInvalidMargin
+	}
+	return nil
+}
+
+// PNG renderiza o conteúdo como uma imagem PNG de Size x Size pixels
+func PNG(content string, opts Options) ([]byte, error) {
+	modules, err := encode(content, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(modules)
+	scale := opts.Size / n
+	if scale < 1 {
+		return nil, ErrInvalidSize // conteúdo grande demais para o tamanho pedido
+	}
+	offset := (opts.Size - n*scale) / 2
+
+	palette := color.Palette{color.White, color.Black}
+	img := image.NewPaletted(image.Rect(0, 0, opts.Size, opts.Size), palette)
+	for y, row := range modules {
+		for x, dark := range row {
+			if !dark {
+				continue
+			}
+			for py := 0; py < scale; py++ {
+				for px := 0; px < scale; px++ {
+					img.SetColorIndex(offset+x*scale+px, offset+y*scale+py, 1)
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// SVG renderiza o conteúdo como SVG vetorial; Size define largura e altura
+func SVG(content string, opts Options) ([]byte, error) {
+	modules, err := encode(content, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(modules)
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, opts.Size, opts.Size, n, n)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, n, n)
+
+	// Um retângulo por sequência horizontal de módulos escuros
+	for y, row := range modules {
+		for x := 0; x < n; {
+			if !row[x] {
+				x++
+				continue
+			}
+			start := x
+			for x < n && row[x] {
+				x++
+			}
+			fmt.Fprintf(&buf, "M%d %dh%dv1h-%dz", start, y, x-start, x-start)
+		}
+	}
+
+	buf.WriteString(`"/></svg>`)
+	return buf.Bytes(), nil
+}
+
+// encode gera a matriz de módulos já com a margem pedida
+func encode(content string, opts Options) ([][]bool, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	code, err := qrcode.New(content, levels[strings.ToUpper(opts.Level)])
+	if err != nil {
+		return nil, err
+	}
+	code.DisableBorder = true
+	bitmap := code.Bitmap()
+
+	n := len(bitmap) + 2*opts.Margin
+	modules := make([][]bool, n)
+	for y := range modules {
+		modules[y] = make([]bool, n)
+	}
+	for y, row := range bitmap {
+		copy(modules[y+opts.Margin][opts.Margin:], row)
+	}
+	return modules, nil
+}